@@ -0,0 +1,104 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/encrypt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newDecryptCmd() *cobra.Command {
+	var identityFile string
+	var outputPath string
+
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt [-i|--identity] key-file [-o|--output] path encrypted-archive",
+		Short: "decrypt a support package encrypted with --encrypt-to",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			inputPath := args[0]
+			if outputPath == "" {
+				for _, extension := range []string{encrypt.AgeExtension, encrypt.PGPExtension} {
+					if strings.HasSuffix(inputPath, extension) {
+						outputPath = strings.TrimSuffix(inputPath, extension)
+					}
+				}
+				if outputPath == "" {
+					return fmt.Errorf("unable to guess the decrypted file name of %s, use --output", inputPath)
+				}
+			}
+
+			identity, err := os.ReadFile(identityFile)
+			if err != nil {
+				return fmt.Errorf("unable to read private key: %w", err)
+			}
+			input, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer input.Close()
+
+			plaintext, err := encrypt.Decrypt(input, identity, readPassphrase)
+			if err != nil {
+				return fmt.Errorf("unable to decrypt %s: %w", inputPath, err)
+			}
+			if outputPath == "-" {
+				_, err = io.Copy(os.Stdout, plaintext)
+				return err
+			}
+			err = archive.WriteFileAtomic(outputPath, func(w io.Writer) error {
+				_, err := io.Copy(w, plaintext)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("unable to decrypt %s: %w", inputPath, err)
+			}
+			fmt.Fprintf(os.Stderr, "Supportpkg decrypted: %s\n", outputPath)
+			return nil
+		},
+	}
+
+	decryptCmd.Flags().StringVarP(&identityFile, "identity", "i", "", "private key file: age identity, SSH private key or armored OpenPGP private key")
+	decryptCmd.Flags().StringVarP(&outputPath, "output", "o", "", "path of the decrypted archive, or - for stdout (default: input path without .age or .gpg)")
+	if err := decryptCmd.MarkFlagRequired("identity"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return decryptCmd
+}
+
+// readPassphrase prompts for the passphrase protecting a private key.
+func readPassphrase() ([]byte, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("the private key is protected by a passphrase, but stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
@@ -0,0 +1,397 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+	"helm.sh/helm/v3/pkg/release"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes/scheme"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the end-to-end tests")
+
+// helmReleaseKind marks the documents of a cluster fixture holding Helm releases.
+var helmReleaseKind = schema.GroupVersionKind{Group: "helm.sh", Version: "v3", Kind: "Release"}
+
+// TestGolden runs the collection command against the fake cluster of testdata/clusters/<product>.yaml,
+// and compares the files of the package with those of testdata/golden/<product>. Run the test with
+// -update to write the golden files after changing the output of the jobs.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		product   string
+		namespace string
+	}{
+		{"nic", "nginx-ingress"},
+		{"ngf", "nginx-gateway"},
+		{"ngx", "web"},
+	}
+	for _, test := range tests {
+		t.Run(test.product, func(t *testing.T) {
+			cluster := loadCluster(t, filepath.Join("testdata", "clusters", test.product+".yaml"))
+			baseDir := fakeCluster(t, cluster)
+
+			dir := t.TempDir()
+			outputPath := filepath.Join(dir, "supportpkg.tar.gz")
+			code := -1
+			rootCmd := newRootCmd(&code)
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetErr(&out)
+			rootCmd.SetArgs([]string{"-p", test.product, "-n", test.namespace, "-o", outputPath, "--concurrency", "1", "--path-collectors"})
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if code != ExitSuccess {
+				t.Fatalf("exit code %d, expected %d:\n%s", code, ExitSuccess, out.String())
+			}
+			if !strings.Contains(out.String(), "Running external collector ") {
+				t.Errorf("external collectors not shown:\n%s", out.String())
+			}
+
+			files := readBundle(t, outputPath, *baseDir)
+			goldenDir := filepath.Join("testdata", "golden", test.product)
+			if *update {
+				writeGolden(t, goldenDir, files)
+				return
+			}
+			golden := readGolden(t, goldenDir)
+			for name, content := range files {
+				want, ok := golden[name]
+				if !ok {
+					t.Errorf("unexpected file %s", name)
+				} else if content != want {
+					t.Errorf("file %s differs from the golden file, run the test with -update if the change is expected:\n%s", name, content)
+				}
+			}
+			for name := range golden {
+				if _, ok := files[name]; !ok {
+					t.Errorf("missing file %s", name)
+				}
+			}
+		})
+	}
+}
+
+// TestSplitOutput checks a package split into volumes is reported by the path of its index,
+// once the archive it replaces is removed.
+func TestSplitOutput(t *testing.T) {
+	fakeCluster(t, loadCluster(t, filepath.Join("testdata", "clusters", "ngx.yaml")))
+	outputPath := filepath.Join(t.TempDir(), "supportpkg.tar.gz")
+	code := -1
+	rootCmd := newRootCmd(&code)
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"-p", "ngx", "-n", "web", "-o", outputPath, "--max-volume-size", "1KB", "--output-format", "json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if code != ExitSuccess {
+		t.Fatalf("exit code %d, expected %d:\n%s", code, ExitSuccess, stderr.String())
+	}
+
+	var summary runSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Output != outputPath+".index.json" || len(summary.Volumes) < 2 {
+		t.Fatalf("unexpected output %s and volumes %v", summary.Output, summary.Volumes)
+	}
+	for _, path := range append([]string{summary.Output}, summary.Volumes...) {
+		if _, err := os.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(outputPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("archive %s left next to its volumes: %v", outputPath, err)
+	}
+	if want := "Supportpkg successfully generated: " + summary.Output + "\n"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, stderr.String())
+	}
+}
+
+// TestNoProduct checks the package is still generated when no pod of the product is found,
+// with the exit code telling so.
+func TestNoProduct(t *testing.T) {
+	fakeCluster(t, loadCluster(t, filepath.Join("testdata", "clusters", "ngx.yaml")))
+	outputPath := filepath.Join(t.TempDir(), "supportpkg.tar.gz")
+	code := -1
+	rootCmd := newRootCmd(&code)
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"-p", "nic", "-n", "web", "-o", outputPath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if code != ExitNoProduct {
+		t.Fatalf("exit code %d, expected %d:\n%s", code, ExitNoProduct, out.String())
+	}
+	files := readBundle(t, outputPath, "")
+	if _, ok := files["resources/web/pods.json"]; !ok {
+		t.Errorf("pods.json not in the package:\n%s", out.String())
+	}
+	if want := "no nic pod found in namespaces web"; !strings.Contains(out.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, out.String())
+	}
+	// The collectors found on PATH only run with --path-collectors
+	for name := range files {
+		if strings.HasPrefix(name, "collectors/") {
+			t.Errorf("external collector run without --path-collectors: %s", name)
+		}
+	}
+}
+
+// loadCluster reads the YAML documents of a cluster fixture. Built-in kinds are served by the core
+// client, definitions by the CRD client and other kinds by the dynamic client.
+func loadCluster(t *testing.T, path string) testcluster.Cluster {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	cluster := testcluster.Cluster{Releases: make(map[string][]*release.Release)}
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		object := &unstructured.Unstructured{}
+		if err = decoder.Decode(&object.Object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(object.Object) == 0 {
+			continue
+		}
+		gvk := object.GroupVersionKind()
+		switch {
+		case gvk == helmReleaseKind:
+			helmRelease := &release.Release{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, helmRelease); err != nil {
+				t.Fatalf("%s: release: %v", path, err)
+			}
+			cluster.Releases[helmRelease.Namespace] = append(cluster.Releases[helmRelease.Namespace], helmRelease)
+		case gvk == apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"):
+			definition := &apiextensionsv1.CustomResourceDefinition{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, definition); err != nil {
+				t.Fatalf("%s: %s: %v", path, object.GetName(), err)
+			}
+			cluster.Definitions = append(cluster.Definitions, definition)
+		case gvk == metricsv1beta1.SchemeGroupVersion.WithKind("PodMetrics"):
+			podMetrics := &metricsv1beta1.PodMetrics{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, podMetrics); err != nil {
+				t.Fatalf("%s: %s: %v", path, object.GetName(), err)
+			}
+			cluster.PodMetrics = append(cluster.PodMetrics, podMetrics)
+		case gvk == metricsv1beta1.SchemeGroupVersion.WithKind("NodeMetrics"):
+			nodeMetrics := &metricsv1beta1.NodeMetrics{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, nodeMetrics); err != nil {
+				t.Fatalf("%s: %s: %v", path, object.GetName(), err)
+			}
+			cluster.NodeMetrics = append(cluster.NodeMetrics, nodeMetrics)
+		case scheme.Scheme.Recognizes(gvk):
+			typed, _ := scheme.Scheme.New(gvk)
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, typed); err != nil {
+				t.Fatalf("%s: %s: %v", path, object.GetName(), err)
+			}
+			cluster.Objects = append(cluster.Objects, typed)
+		default:
+			cluster.CustomObjects = append(cluster.CustomObjects, object)
+		}
+	}
+	return cluster
+}
+
+// fakeCluster makes the collection command use fake clients serving the cluster, with a kubeconfig
+// naming the context and cluster of the package. It returns where the base directory of the
+// collector is stored once created.
+func fakeCluster(t *testing.T, cluster testcluster.Cluster) *string {
+	t.Helper()
+	kubeConfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+  - name: golden
+    cluster:
+      server: https://127.0.0.1:6443
+contexts:
+  - name: golden
+    context:
+      cluster: golden
+      user: golden
+current-context: golden
+users:
+  - name: golden
+    user:
+      token: golden
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeConfig)
+	// The external collector of testdata/collectors is found on PATH
+	collectors, err := filepath.Abs(filepath.Join("testdata", "collectors"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", collectors+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cluster.ServerVersion = &version.Info{Major: "1", Minor: "33", GitVersion: "v1.33.1", Platform: "linux/amd64"}
+
+	var baseDir string
+	t.Cleanup(func() { newDataCollector = nil })
+	newDataCollector = func(workDir string, namespaces ...string) (*data_collector.DataCollector, error) {
+		dc, err := data_collector.NewDataCollectorInDir(workDir, namespaces...)
+		if err != nil {
+			return nil, err
+		}
+		baseDir = dc.BaseDir
+		testcluster.Install(t, dc, cluster)
+		return dc, nil
+	}
+	return &baseDir
+}
+
+// readBundle returns the normalized content of the files of a package, by path below its root directory.
+func readBundle(t *testing.T, path string, baseDir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := archive.Walk(path, func(name string, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, name, _ = strings.Cut(name, "/")
+		files[name] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		switch name {
+		case "supportpkg.log":
+			files[name] = normalizeLog(t, content, baseDir)
+		case manifest.FileName:
+			files[name] = normalizeManifest(t, content)
+		}
+	}
+	return files
+}
+
+// normalizeLog drops the values that change with each run from the records of the log, which are
+// sorted as the jobs may log concurrently. Paths are made relative to the base directory.
+func normalizeLog(t *testing.T, content string, baseDir string) string {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		record := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("supportpkg.log: %v", err)
+		}
+		for key, value := range record {
+			switch key {
+			case "time", "args", "duration":
+				record[key] = "<" + key + ">"
+			case "file":
+				if path, ok := value.(string); ok {
+					record[key] = filepath.ToSlash(strings.TrimPrefix(path, baseDir+string(filepath.Separator)))
+				}
+			}
+		}
+		normalized, _ := json.Marshal(record)
+		lines = append(lines, string(normalized))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// normalizeManifest drops the creation time of the package, the checksum of the log, whose
+// records are dated, and the duration and path of the external collectors.
+func normalizeManifest(t *testing.T, content string) string {
+	t.Helper()
+	var m manifest.Manifest
+	if err := json.Unmarshal([]byte(content), &m); err != nil {
+		t.Fatalf("%s: %v", manifest.FileName, err)
+	}
+	m.Created = time.Time{}
+	for i, file := range m.Files {
+		if file.Path == "supportpkg.log" {
+			m.Files[i].Size = 0
+			m.Files[i].SHA256 = ""
+		}
+	}
+	for i, run := range m.Collectors {
+		m.Collectors[i].Executable = filepath.Base(run.Executable)
+		m.Collectors[i].DurationSeconds = 0
+	}
+	normalized, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(normalized) + "\n"
+}
+
+func readGolden(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("%v, run the test with -update to write the golden files", err)
+	}
+	return testcluster.ReadFiles(t, dir)
+}
+
+func writeGolden(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
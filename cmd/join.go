@@ -0,0 +1,49 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/spf13/cobra"
+)
+
+func newJoinCmd() *cobra.Command {
+	var outputPath string
+
+	joinCmd := &cobra.Command{
+		Use:   "join [-o|--output] path index-or-volume",
+		Short: "reassemble a support package split with --max-volume-size",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			joined, err := archive.Join(archive.IndexPath(args[0]), outputPath)
+			if err != nil {
+				return fmt.Errorf("unable to join volumes: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Supportpkg reassembled: %s\n", joined)
+			return nil
+		},
+	}
+
+	joinCmd.Flags().StringVarP(&outputPath, "output", "o", "", "path of the reassembled archive (default: its original name, next to the index)")
+	return joinCmd
+}
@@ -0,0 +1,77 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/jobs"
+	"github.com/spf13/cobra"
+)
+
+func newListJobsCmd() *cobra.Command {
+	var product string
+
+	listJobsCmd := &cobra.Command{
+		Use:   "list-jobs [-p|--product] [" + strings.Join(jobs.Products(), ",") + "]",
+		Short: "list the jobs run for each product, with their timeout and required permissions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			products := jobs.Products()
+			if product != "" {
+				products = []string{product}
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for i, product := range products {
+				jobList, err := jobs.ProductJobList(product)
+				if err != nil {
+					return err
+				}
+				// The external collectors found on PATH run for every product
+				for _, executable := range jobs.FindExternalCollectors() {
+					jobList = append(jobList, jobs.ExternalCollectorJob(executable))
+				}
+				if i > 0 {
+					fmt.Fprintln(tw)
+				}
+				fmt.Fprintf(tw, "Product %s:\n", product)
+				fmt.Fprintln(tw, "JOB\tTIMEOUT\tPERMISSIONS\tDESCRIPTION")
+				for _, job := range jobList {
+					permissions := "-"
+					if len(job.Permissions) > 0 {
+						permissions = strings.Join(job.Permissions, ", ")
+					}
+					timeout := job.Timeout.String()
+					if job.PerPod {
+						timeout += "/pod"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", job.Name, timeout, permissions, job.Description)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+
+	listJobsCmd.Flags().StringVarP(&product, "product", "p", "", "only list the jobs of this product")
+	return listJobsCmd
+}
@@ -19,21 +19,78 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"slices"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dustin/go-humanize"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/encrypt"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/jobs"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/retry"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/supportpkg"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/throttle"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/upload"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/version"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// newDataCollector, when set, creates the collector of a run instead of the kubeconfig, the
+// end-to-end tests set it to serve a fake cluster.
+var newDataCollector func(workDir string, namespaces ...string) (*data_collector.DataCollector, error)
+
 func Execute() {
+	exitCode := ExitSuccess
+	rootCmd := newRootCmd(&exitCode)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
+}
+
+// newRootCmd builds the command line, which stores the exit code of the collection in exitCode.
+func newRootCmd(exitCode *int) *cobra.Command {
 
 	var namespaces []string
 	var product string
-	var jobList []jobs.Job
+	var allCRDVersions bool
+	var allNamespaces bool
+	var output data_collector.OutputOptions
+	var format string
+	var encryptTo string
+	var sign bool
+	var signKeyFile string
+	var uploadOptions upload.Options
+	var maxVolumeSize string
+	var outputFormat string
+	var concurrency int
+	var verbose bool
+	var jobTimeouts map[string]string
+	var timeoutScale float64
+	var deadline time.Duration
+	retryPolicy := retry.DefaultPolicy
+	var qps float64
+	var burst int
+	var includeJobs []string
+	var excludeJobs []string
+	var workDir string
+	var resume bool
+	var collectors []string
+	var pathCollectors bool
 
 	var rootCmd = &cobra.Command{
 		Use:   "nginx-supportpkg",
@@ -41,56 +98,303 @@ func Execute() {
 		Long:  `nginx-supportpkg - a tool to create Ingress Controller diagnostics package`,
 		Run: func(cmd *cobra.Command, args []string) {
 
-			collector, err := data_collector.NewDataCollector(namespaces...)
-			if err != nil {
-				fmt.Println(fmt.Errorf("unable to start data collector: %s", err))
-				os.Exit(1)
+			stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+			// Console output moves to stderr when stdout is taken by the archive or the JSON summary
+			streaming := output.Path == data_collector.StreamOutput
+			var out io.Writer = stdout
+			var summaryOut io.Writer = stdout
+			if streaming {
+				summaryOut = stderr
+			}
+			switch outputFormat {
+			case OutputFormatText:
+				if streaming {
+					out = stderr
+				}
+			case OutputFormatJSON:
+				out = stderr
+			default:
+				fmt.Fprintf(stderr, "Error: invalid --output-format %q, must be %s or %s\n", outputFormat, OutputFormatText, OutputFormatJSON)
+				*exitCode = ExitFailure
+				return
 			}
 
-			collector.Logger.Printf("Starting kubectl-nginx-supportpkg - version: %s - build: %s", version.Version, version.Build)
-			collector.Logger.Printf("Input args are %v", os.Args)
+			summary := newRunSummary(product, namespaces)
+			ctx := context.Background()
+			if deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, summary.Started.Add(deadline))
+				defer cancel()
+			}
+			// exit ends the run with the exit code, the caller returns right after it
+			exit := func(code int, err error) {
+				if err != nil {
+					fmt.Fprintf(out, "Error: %s\n", err)
+				}
+				if outputFormat == OutputFormatJSON {
+					summary.write(summaryOut, code, err)
+				}
+				*exitCode = code
+			}
 
-			switch product {
-			case "nic":
-				jobList = slices.Concat(jobs.CommonJobList(), jobs.NICJobList())
-			case "ngf":
-				jobList = slices.Concat(jobs.CommonJobList(), jobs.NGFJobList())
-			case "ngx":
-				jobList = slices.Concat(jobs.CommonJobList(), jobs.NGXJobList())
-			default:
-				fmt.Printf("Error: product must be in the following list: [nic, ngf, ngx]\n")
-				os.Exit(1)
-			}
-
-			if collector.AllNamespacesExist() {
-				failedJobs := 0
-				for _, job := range jobList {
-					fmt.Printf("Running job %s...", job.Name)
-					err = job.Collect(collector)
-					if err != nil {
-						fmt.Printf(" Error: %s\n", err)
-						failedJobs++
-					} else {
-						fmt.Print(" OK\n")
-					}
+			if streaming {
+				if file, ok := stdout.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+					exit(ExitFailure, errors.New("refusing to stream the archive to a terminal, redirect stdout to a file or a pipe"))
+					return
+				}
+				if uploadOptions.Target != "" {
+					exit(ExitFailure, errors.New("--upload cannot be used when streaming the archive to stdout"))
+					return
+				}
+				if maxVolumeSize != "" {
+					exit(ExitFailure, errors.New("--max-volume-size cannot be used when streaming the archive to stdout"))
+					return
+				}
+				if workDir != "" {
+					exit(ExitFailure, errors.New("--work-dir cannot be used when streaming the archive to stdout"))
+					return
+				}
+			}
+
+			if resume && workDir == "" {
+				exit(ExitFailure, errors.New("--resume requires the --work-dir of the interrupted collection"))
+				return
+			}
+
+			if retryPolicy.Retries < 0 || retryPolicy.InitialBackoff < 0 || retryPolicy.MaxBackoff < 0 {
+				exit(ExitFailure, errors.New("--retries, --retry-backoff and --retry-max-backoff must not be negative"))
+				return
+			}
+
+			if qps > 0 && burst < 1 {
+				exit(ExitFailure, errors.New("--burst must be at least 1"))
+				return
+			}
+
+			var volumeSize uint64
+			if maxVolumeSize != "" {
+				var err error
+				if volumeSize, err = humanize.ParseBytes(maxVolumeSize); err != nil || volumeSize == 0 {
+					exit(ExitFailure, fmt.Errorf("invalid --max-volume-size %q, use a size such as 100MB or 1GiB", maxVolumeSize))
+					return
+				}
+				if uploadOptions.Target != "" && !strings.HasPrefix(uploadOptions.Target, "s3://") {
+					exit(ExitFailure, errors.New("volumes can only be uploaded to an s3:// target, a presigned URL receives a single file"))
+					return
+				}
+			}
+
+			output.Format = archive.TarGz
+			if cmd.Flags().Changed("format") {
+				var err error
+				if output.Format, err = archive.ParseFormat(format); err != nil {
+					exit(ExitFailure, err)
+					return
 				}
+			} else if inferred, ok := archive.FormatFromName(strings.TrimSuffix(strings.TrimSuffix(output.Path, encrypt.AgeExtension), encrypt.PGPExtension)); ok {
+				output.Format = inferred
+			}
+
+			if encryptTo != "" {
+				var err error
+				if output.Recipient, err = encrypt.ParseRecipient(encryptTo); err != nil {
+					exit(ExitFailure, fmt.Errorf("unable to use encryption key: %w", err))
+					return
+				}
+			}
+
+			var ephemeralKey ed25519.PublicKey
+			if signKeyFile != "" {
+				var err error
+				if output.SigningKey, err = manifest.ParsePrivateKey(signKeyFile); err != nil {
+					exit(ExitFailure, fmt.Errorf("unable to use signing key: %w", err))
+					return
+				}
+			} else if sign {
+				var err error
+				if ephemeralKey, output.SigningKey, err = ed25519.GenerateKey(nil); err != nil {
+					exit(ExitFailure, fmt.Errorf("unable to generate signing key: %w", err))
+					return
+				}
+			}
+
+			overrides := make(map[string]time.Duration)
+			for name, value := range jobTimeouts {
+				var err error
+				if overrides[name], err = time.ParseDuration(value); err != nil {
+					exit(ExitFailure, fmt.Errorf("invalid --job-timeout for %s: %w", name, err))
+					return
+				}
+			}
 
-				tarFile, err := collector.WrapUp(product)
+			// External collectors given with --collector run with those found on PATH, once each
+			var executables []string
+			for _, collector := range collectors {
+				executable, err := exec.LookPath(collector)
 				if err != nil {
-					fmt.Println(fmt.Errorf("error when wrapping up: %s", err))
-					os.Exit(1)
-				} else {
-					if failedJobs == 0 {
-						fmt.Printf("Supportpkg successfully generated: %s\n", tarFile)
-					} else {
-						fmt.Printf("WARNING: %d failed job(s)\n", failedJobs)
-						fmt.Printf("Supportpkg generated with warnings: %s\n", tarFile)
+					exit(ExitFailure, fmt.Errorf("invalid --collector: %w", err))
+					return
+				}
+				executables = append(executables, executable)
+			}
+			if pathCollectors {
+				for _, executable := range jobs.FindExternalCollectors() {
+					if !slices.ContainsFunc(executables, func(other string) bool { return sameFile(executable, other) }) {
+						executables = append(executables, executable)
 					}
+				}
+			}
+			// Collectors run with the privileges of the user, the selected ones are shown before anything runs
+			for _, executable := range executables {
+				job := jobs.ExternalCollectorJob(executable)
+				if selected, err := jobs.Filter([]jobs.Job{job}, includeJobs, excludeJobs); err == nil && len(selected) > 0 {
+					fmt.Fprintf(out, "Running external collector %s as job %s\n", executable, job.Name)
+				}
+			}
 
+			// The first interrupt stops the collection like the deadline, and a partial supportpkg is
+			// written. Signals are then handled by default, so that a second one ends the process.
+			ctx, interrupt := context.WithCancelCause(ctx)
+			defer interrupt(nil)
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				if _, ok := <-signals; ok {
+					signal.Stop(signals)
+					fmt.Fprintln(out, "Interrupted, writing a partial supportpkg...")
+					interrupt(errInterrupted)
 				}
+			}()
+
+			// With --qps 0 requests are not rate limited, which the library asks with a negative rate
+			if qps == 0 {
+				qps = -1
+			}
+			renderer := newRenderer(out, verbose)
+			options := supportpkg.Options{
+				Namespaces:     namespaces,
+				Products:       []string{product},
+				IncludeJobs:    includeJobs,
+				ExcludeJobs:    excludeJobs,
+				Collectors:     executables,
+				JobTimeouts:    overrides,
+				TimeoutScale:   timeoutScale,
+				Concurrency:    max(concurrency, 1),
+				Output:         output,
+				AllNamespaces:  allNamespaces,
+				AllCRDVersions: allCRDVersions,
+				Retry:          &retryPolicy,
+				QPS:            qps,
+				Burst:          burst,
+				WorkDir:        workDir,
+				Resume:         resume,
+				Progress:       renderer.Handle,
+				Args:           os.Args,
+				NewCollector:   newDataCollector,
+			}
+			if streaming {
+				options.Writer = stdout
+			}
+			if verbose {
+				options.Log = stderr
+			}
+			result, err := supportpkg.Collect(ctx, options)
+			renderer.Close()
+			signal.Stop(signals)
+			close(signals)
+			interrupted := errors.Is(context.Cause(ctx), errInterrupted)
+
+			var pkg supportpkg.Package
+			if len(result.Packages) > 0 {
+				pkg = result.Packages[0]
+			}
+			for _, job := range pkg.Jobs {
+				summary.addJob(job)
+			}
+			if err != nil {
+				switch {
+				case errors.Is(err, supportpkg.ErrPreflight):
+					exit(ExitPreflight, err)
+				default:
+					if pkg.StagingDir != "" {
+						fmt.Fprintf(out, "Supportpkg is incomplete, the collected files are kept in %s\n", pkg.StagingDir)
+					}
+					exit(ExitFailure, err)
+				}
+				return
+			}
+
+			tarFile := pkg.Path
+			if streaming {
+				tarFile = "stdout"
+			}
+			summary.Output = tarFile
+
+			// The archive is split before it is reported, as it is replaced by the index of its volumes
+			files := []string{tarFile}
+			if info, err := os.Stat(tarFile); err == nil && volumeSize > 0 && uint64(info.Size()) > volumeSize {
+				if files, err = archive.Split(tarFile, int64(volumeSize)); err != nil {
+					exit(ExitFailure, fmt.Errorf("unable to split the supportpkg into volumes: %w", err))
+					return
+				}
+				tarFile = files[0]
+				summary.Output = files[0]
+				summary.Volumes = files[1:]
+				fmt.Fprintf(out, "Supportpkg split into %d volume(s): %s\n", len(files)-1, strings.Join(files[1:], ", "))
+				fmt.Fprintf(out, "Reassemble them with \"nginx-supportpkg join %s\"\n", files[0])
+			}
+
+			if ephemeralKey != nil {
+				fmt.Fprintf(out, "Supportpkg signed with ephemeral public key: %s\n", manifest.EncodePublicKey(ephemeralKey))
+			}
+			if resumed := pkg.Count(supportpkg.JobResumed); resumed > 0 {
+				fmt.Fprintf(out, "Resumed: %d job(s) collected by the interrupted run\n", resumed)
+			}
+			// Without product pods the supportpkg holds the namespaces only, which the exit code tells
+			var exitErr error
+			if len(pkg.ProductPods) == 0 {
+				fmt.Fprintf(out, "WARNING: no %s pod found in namespaces %s, the supportpkg only holds the resources of the namespaces\n", product, strings.Join(namespaces, ", "))
+				exitErr = fmt.Errorf("no %s pod found in namespaces %s", product, strings.Join(namespaces, ", "))
+			}
+			exitCode := ExitSuccess
+			failedJobs, skippedJobs := pkg.Count(supportpkg.JobFailed), pkg.Count(supportpkg.JobSkipped)
+			if failedJobs == 0 && skippedJobs == 0 {
+				fmt.Fprintf(out, "Supportpkg successfully generated: %s\n", tarFile)
 			} else {
-				fmt.Println(" Error: Some namespaces do not exist")
+				exitCode = ExitPartial
+				if failedJobs > 0 {
+					fmt.Fprintf(out, "WARNING: %d failed job(s)\n", failedJobs)
+				}
+				if skippedJobs > 0 && interrupted {
+					fmt.Fprintf(out, "WARNING: %d job(s) skipped after the interrupt\n", skippedJobs)
+				} else if skippedJobs > 0 {
+					fmt.Fprintf(out, "WARNING: %d job(s) skipped after the %s deadline\n", skippedJobs, deadline)
+				}
+				fmt.Fprintf(out, "Supportpkg generated with warnings: %s\n", tarFile)
+				if pkg.StagingDir != "" {
+					fmt.Fprintf(out, "Run the remaining jobs with \"--resume --work-dir %s\" and the same options\n", workDir)
+				}
+			}
+
+			if uploadOptions.Target != "" {
+				fmt.Fprintf(out, "Uploading %s...", strings.Join(files, ", "))
+				summary.Uploads, err = upload.Upload(context.Background(), uploadOptions, files...)
+				if err != nil {
+					fmt.Fprintln(out)
+					exit(ExitFailure, err)
+					return
+				}
+				fmt.Fprint(out, " OK\n")
+				for _, location := range summary.Uploads {
+					fmt.Fprintf(out, "Supportpkg uploaded to %s\n", location)
+				}
 			}
+
+			if exitErr != nil {
+				exitCode = ExitNoProduct
+			}
+			exit(exitCode, exitErr)
 		},
 	}
 
@@ -106,20 +410,76 @@ func Execute() {
 		os.Exit(1)
 	}
 
+	rootCmd.Flags().StringVarP(&output.Path, "output", "o", "", "path of the generated archive, or - to stream it to stdout; overrides --output-dir and --name-template")
+	rootCmd.Flags().StringVar(&output.Dir, "output-dir", ".", "directory where the archive is written")
+	rootCmd.Flags().StringVar(&output.NameTemplate, "name-template", data_collector.DefaultNameTemplate, "archive name template, using {{.Product}}, {{.Cluster}}, {{.Context}}, {{.Timestamp}} (RFC3339) and {{.Unix}}")
+	rootCmd.Flags().StringVar(&format, "format", string(archive.TarGz), "archive format: tar.gz, tar.zst or zip")
+	rootCmd.Flags().StringVar(&encryptTo, "encrypt-to", "", "encrypt the archive for an age recipient, an SSH public key, a file holding an age or OpenPGP public key, or \"default\" for the built-in support key")
+	rootCmd.Flags().BoolVar(&sign, "sign", false, "sign the manifest with a generated ephemeral ed25519 key, whose public key is printed")
+	rootCmd.Flags().StringVar(&signKeyFile, "sign-key", "", "sign the manifest with the ed25519 private key in this PKCS #8 PEM file")
+	rootCmd.Flags().StringVar(&uploadOptions.Target, "upload", "", "upload the archive to s3://bucket/prefix or to a presigned https:// URL, which takes a single file of at most 5 GiB")
+	rootCmd.Flags().StringVar(&uploadOptions.Endpoint, "upload-endpoint", "", "S3-compatible endpoint used with s3:// uploads (default $AWS_ENDPOINT_URL or AWS S3)")
+	rootCmd.Flags().StringVar(&maxVolumeSize, "max-volume-size", "", "split archives larger than this size, such as 100MB, into numbered volumes with an index")
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", OutputFormatText, "format of the run report: text, or json for a summary on stdout with the console output on stderr")
+	rootCmd.Flags().StringSliceVar(&includeJobs, "include-jobs", nil, "only run the jobs matching these glob patterns, such as exec-*; see list-jobs")
+	rootCmd.Flags().StringSliceVar(&excludeJobs, "exclude-jobs", nil, "skip the jobs matching these glob patterns, such as collect-pods-logs")
+	rootCmd.Flags().StringToStringVar(&jobTimeouts, "job-timeout", nil, "override the timeout of jobs, as name=duration such as exec-nginx-t=1m; per pod for the exec jobs")
+	rootCmd.Flags().Float64Var(&timeoutScale, "timeout-scale", 1, "multiply the timeout of every job, such as 2 for slow clusters")
+	rootCmd.Flags().DurationVar(&deadline, "deadline", 0, "overall time limit, such as 10m, after which running jobs are cancelled and the others skipped before the package is written")
+	rootCmd.Flags().IntVar(&retryPolicy.Retries, "retries", retryPolicy.Retries, "number of retries of API requests and pod executions failing with transient errors, 0 to disable")
+	rootCmd.Flags().DurationVar(&retryPolicy.InitialBackoff, "retry-backoff", retryPolicy.InitialBackoff, "delay before the first retry, doubled for each of the next ones")
+	rootCmd.Flags().DurationVar(&retryPolicy.MaxBackoff, "retry-max-backoff", retryPolicy.MaxBackoff, "maximum delay between retries, unless the API server asks for more with Retry-After")
+	rootCmd.Flags().Float64Var(&qps, "qps", throttle.DefaultQPS, "maximum rate of requests to the API server, lowered while it answers with 429; 0 for no limit")
+	rootCmd.Flags().IntVar(&burst, "burst", throttle.DefaultBurst, "maximum burst of requests to the API server above --qps")
+	rootCmd.Flags().StringVar(&workDir, "work-dir", "", "stage the collected files and a checkpoint in this directory, kept until every job is collected, instead of a temporary directory")
+	rootCmd.Flags().BoolVar(&resume, "resume", false, "resume the collection staged in --work-dir, running only the jobs not yet collected")
+	rootCmd.Flags().StringSliceVar(&collectors, "collector", nil, "run this external collector executable, which reads a JSON context on stdin and writes files in its output directory")
+	rootCmd.Flags().BoolVar(&pathCollectors, "path-collectors", false, "also run the external collectors found on PATH, named "+jobs.ExternalCollectorPrefix+"*, besides those given with --collector")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", 4, "number of jobs run in parallel")
+	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "mirror the records of supportpkg.log to stderr")
+	rootCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "collect namespaced custom resources from all namespaces")
+	rootCmd.Flags().BoolVar(&allCRDVersions, "all-crd-versions", false, "collect custom resources at every served version instead of the storage version only")
+
 	versionStr := "nginx-supportpkg - version: " + version.Version + " - build: " + version.Build + "\n"
 	rootCmd.SetVersionTemplate(versionStr)
 	rootCmd.Version = versionStr
 
+	products := strings.Join(jobs.Products(), ",")
 	rootCmd.SetUsageTemplate(
 		versionStr +
 			"Usage:" +
 			"\n nginx-supportpkg -h|--help" +
 			"\n nginx-supportpkg -v|--version" +
-			"\n nginx-supportpkg [-n|--namespace] ns1 [-n|--namespace] ns2 [-p|--product] [nic,ngf,ngx]" +
-			"\n nginx-supportpkg [-n|--namespace] ns1,ns2 [-p|--product] [nic,ngf,ngx] \n")
+			"\n nginx-supportpkg [-n|--namespace] ns1 [-n|--namespace] ns2 [-p|--product] [" + products + "]" +
+			"\n nginx-supportpkg [-n|--namespace] ns1,ns2 [-p|--product] [" + products + "]" +
+			"\n nginx-supportpkg [-n|--namespace] ns1,ns2 [-p|--product] [" + products + "] --work-dir dir [--resume]" +
+			"\n nginx-supportpkg decrypt [-i|--identity] key-file [-o|--output] path encrypted-archive" +
+			"\n nginx-supportpkg verify [--public-key] key archive" +
+			"\n nginx-supportpkg join [-o|--output] path index-or-volume" +
+			"\n nginx-supportpkg list-jobs [-p|--product] [" + products + "] \n")
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	rootCmd.AddCommand(newDecryptCmd())
+	rootCmd.AddCommand(newVerifyCmd())
+	rootCmd.AddCommand(newJoinCmd())
+	rootCmd.AddCommand(newListJobsCmd())
+
+	return rootCmd
+}
+
+// newRenderer shows the progress of the jobs with spinners on a terminal, or with one line per job otherwise.
+// Verbose runs print one line per job too, as the spinners would be garbled by the logs on stderr.
+func newRenderer(out io.Writer, verbose bool) *progress.Renderer {
+	if file, ok := out.(*os.File); ok && !verbose && term.IsTerminal(int(file.Fd())) && os.Getenv("TERM") != "dumb" {
+		if width, _, err := term.GetSize(int(file.Fd())); err == nil {
+			return progress.NewTTYRenderer(out, width)
+		}
 	}
+	return progress.NewPlainRenderer(out)
+}
+
+// sameFile tells whether two paths name the same file.
+func sameFile(a, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	return aErr == nil && bErr == nil && os.SameFile(aInfo, bInfo)
 }
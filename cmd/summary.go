@@ -0,0 +1,120 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/supportpkg"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/version"
+)
+
+// Exit codes of the collection command, documented in the README.
+const (
+	ExitSuccess = 0
+	// ExitFailure is returned for invalid options, and when the package cannot be written or uploaded
+	ExitFailure = 1
+	// ExitPartial is returned when the package was generated but some jobs failed
+	ExitPartial = 2
+	// ExitPreflight is returned when the cluster or one of the namespaces cannot be reached
+	ExitPreflight = 3
+	// ExitNoProduct is returned when no pod of the product runs in the namespaces, the package is
+	// then generated with the resources of the namespaces only
+	ExitNoProduct = 4
+)
+
+const (
+	OutputFormatText = "text"
+	OutputFormatJSON = "json"
+)
+
+// errInterrupted is the cause of the cancellation of the collection on SIGINT or SIGTERM.
+var errInterrupted = errors.New("interrupted")
+
+// runSummary is printed with --output-format json for pipelines and scripts.
+type runSummary struct {
+	Product         string       `json:"product"`
+	Namespaces      []string     `json:"namespaces"`
+	Version         string       `json:"version"`
+	Build           string       `json:"build"`
+	Status          string       `json:"status"`
+	ExitCode        int          `json:"exitCode"`
+	Error           string       `json:"error,omitempty"`
+	Output          string       `json:"output,omitempty"`
+	Volumes         []string     `json:"volumes,omitempty"`
+	Uploads         []string     `json:"uploads,omitempty"`
+	Started         time.Time    `json:"started"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	Jobs            []jobSummary `json:"jobs"`
+}
+
+type jobSummary struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+func newRunSummary(product string, namespaces []string) *runSummary {
+	return &runSummary{
+		Product:    product,
+		Namespaces: namespaces,
+		Version:    version.Version,
+		Build:      version.Build,
+		Started:    time.Now().UTC(),
+		Jobs:       []jobSummary{},
+	}
+}
+
+func (s *runSummary) addJob(job supportpkg.JobResult) {
+	summary := jobSummary{Name: job.Name, Status: string(job.Status), DurationSeconds: job.Duration.Seconds()}
+	if job.Err != nil {
+		summary.Error = job.Err.Error()
+	}
+	s.Jobs = append(s.Jobs, summary)
+}
+
+// write completes the summary with the exit code and the error ending the run, if any.
+func (s *runSummary) write(w io.Writer, exitCode int, err error) {
+	s.ExitCode = exitCode
+	switch exitCode {
+	case ExitSuccess:
+		s.Status = "success"
+	case ExitPartial, ExitNoProduct:
+		s.Status = "partial"
+	default:
+		s.Status = "failed"
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.DurationSeconds = time.Since(s.Started).Seconds()
+
+	summaryBytes, jsonErr := json.MarshalIndent(s, "", "  ")
+	if jsonErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: unable to write the summary: %s\n", jsonErr)
+		return
+	}
+	fmt.Fprintln(w, string(summaryBytes))
+}
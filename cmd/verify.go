@@ -0,0 +1,86 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package cmd
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var publicKeyValue string
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [--public-key] key archive",
+		Short: "check the files of a support package against its manifest and signature",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			out := cmd.OutOrStdout()
+
+			var publicKey ed25519.PublicKey
+			if publicKeyValue != "" {
+				var err error
+				if publicKey, err = manifest.ParsePublicKey(publicKeyValue); err != nil {
+					return err
+				}
+			}
+
+			report, err := manifest.Verify(args[0], publicKey)
+			if report == nil {
+				return err
+			}
+			fmt.Fprintf(out, "Supportpkg %s - product: %s - version: %s - created: %s\n", args[0], report.Manifest.Product, report.Manifest.Version, report.Manifest.Created.Format(time.RFC3339))
+			fmt.Fprintf(out, "%d of %d file(s) verified\n", report.Verified, len(report.Manifest.Files))
+			for _, name := range report.Missing {
+				fmt.Fprintf(out, "\tmissing: %s\n", name)
+			}
+			for _, name := range report.Mismatched {
+				fmt.Fprintf(out, "\tmodified: %s\n", name)
+			}
+			for _, name := range report.Unexpected {
+				fmt.Fprintf(out, "\tnot in manifest: %s\n", name)
+			}
+
+			switch {
+			case err != nil:
+				fmt.Fprintf(out, "Signature: INVALID\n")
+			case !report.Signed:
+				fmt.Fprintf(out, "Signature: none\n")
+			case publicKey == nil:
+				fmt.Fprintf(out, "Signature: valid, made by %s; use --public-key to check it is the expected key\n", manifest.EncodePublicKey(report.SignedBy))
+			default:
+				fmt.Fprintf(out, "Signature: valid, made by %s\n", manifest.EncodePublicKey(report.SignedBy))
+			}
+
+			if !report.Valid() {
+				err = errors.Join(err, errors.New("the support package does not match its manifest"))
+			}
+			return err
+		},
+	}
+
+	verifyCmd.Flags().StringVar(&publicKeyValue, "public-key", "", "ed25519 public key the manifest must be signed with, in base64 or as a PEM file")
+	return verifyCmd
+}
@@ -0,0 +1,181 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+// Package testcluster serves the objects of a Kubernetes cluster to a data collector from fake
+// clients, for the tests of the jobs and of the collection command.
+package testcluster
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmClient "github.com/mittwald/go-helm-client"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsFake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// Cluster describes the objects the fake clients serve.
+type Cluster struct {
+	// Objects are the built-in objects, served by the core client
+	Objects []runtime.Object
+	// Definitions are the custom resource definitions, which also tell the dynamic client the resources of CustomObjects
+	Definitions   []runtime.Object
+	CustomObjects []*unstructured.Unstructured
+	NodeMetrics   []*metricsv1beta1.NodeMetrics
+	PodMetrics    []*metricsv1beta1.PodMetrics
+	// Releases are the Helm releases, by namespace
+	Releases map[string][]*release.Release
+	// ServerVersion is the version the discovery client reports, the default of the fake when nil
+	ServerVersion *version.Info
+	// Exec answers the commands run in the pods, it echoes them when nil
+	Exec data_collector.ExecutorFunc
+	// Failures makes the requests fail, by "verb resource" such as "list pods", "list releases"
+	// failing the Helm clients
+	Failures map[string]error
+}
+
+// Install sets fake clients serving the cluster on the collector, with a Helm client for each of its namespaces.
+func Install(t testing.TB, dc *data_collector.DataCollector, cluster Cluster) {
+	t.Helper()
+
+	coreClientSet := fake.NewClientset(cluster.Objects...)
+	if cluster.ServerVersion != nil {
+		coreClientSet.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = cluster.ServerVersion
+	}
+	crdClientSet := crdFake.NewClientset(cluster.Definitions...)
+	// Metrics are added with their resource, which the tracker cannot guess from the NodeMetrics and PodMetrics kinds
+	metricsClientSet := metricsFake.NewSimpleClientset()
+	for _, nodeMetrics := range cluster.NodeMetrics {
+		if err := metricsClientSet.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("nodes"), nodeMetrics, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, podMetrics := range cluster.PodMetrics {
+		if err := metricsClientSet.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), podMetrics, podMetrics.Namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Custom resources are added with the resource of their definition too, as the tracker gets
+	// plurals such as gateways wrong
+	listKinds := make(map[schema.GroupVersionResource]string)
+	resources := make(map[schema.GroupVersionKind]schema.GroupVersionResource)
+	for _, object := range cluster.Definitions {
+		definition := object.(*apiextensionsv1.CustomResourceDefinition)
+		for _, version := range definition.Spec.Versions {
+			gvr := schema.GroupVersionResource{Group: definition.Spec.Group, Version: version.Name, Resource: definition.Spec.Names.Plural}
+			listKinds[gvr] = definition.Spec.Names.Kind + "List"
+			resources[gvr.GroupVersion().WithKind(definition.Spec.Names.Kind)] = gvr
+		}
+	}
+	dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	for _, object := range cluster.CustomObjects {
+		gvr, ok := resources[object.GroupVersionKind()]
+		if !ok {
+			t.Fatalf("no definition for %v", object.GroupVersionKind())
+		}
+		if err := dynamicClient.Tracker().Create(gvr, object, object.GetNamespace()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for request, err := range cluster.Failures {
+		verb, resource, _ := strings.Cut(request, " ")
+		reactor := func(k8sTesting.Action) (bool, runtime.Object, error) { return true, nil, err }
+		coreClientSet.PrependReactor(verb, resource, reactor)
+		crdClientSet.PrependReactor(verb, resource, reactor)
+		metricsClientSet.PrependReactor(verb, resource, reactor)
+		dynamicClient.PrependReactor(verb, resource, reactor)
+	}
+
+	exec := cluster.Exec
+	if exec == nil {
+		exec = echoExec
+	}
+	helmClients := make(map[string]helmClient.Client)
+	for _, namespace := range dc.Namespaces {
+		helmClients[namespace] = helmStub{releases: cluster.Releases[namespace], err: cluster.Failures["list releases"]}
+	}
+
+	dc.K8sCoreClientSet = coreClientSet
+	dc.K8sCrdClientSet = crdClientSet
+	dc.K8sMetricsClientSet = metricsClientSet
+	dc.K8sDynamicClient = dynamicClient
+	dc.K8sHelmClientSet = helmClients
+	dc.K8sExecutor = exec
+}
+
+// helmStub serves releases and settings, the other methods of the client are not used by the jobs.
+type helmStub struct {
+	helmClient.Client
+	releases []*release.Release
+	err      error
+}
+
+func (h helmStub) ListDeployedReleases() ([]*release.Release, error) {
+	return h.releases, h.err
+}
+
+func (h helmStub) GetSettings() *cli.EnvSettings {
+	return &cli.EnvSettings{KubeConfig: "/kube/config", Debug: false}
+}
+
+// echoExec answers each command with the container and the command it was run with.
+func echoExec(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s/%s/%s: %s\n", namespace, pod, container, strings.Join(command, " "))), nil
+}
+
+// ReadFiles returns the content of the files under dir, by path relative to it.
+func ReadFiles(t testing.TB, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relativePath, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(relativePath)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
@@ -0,0 +1,280 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Format string
+
+const (
+	TarGz  Format = "tar.gz"
+	TarZst Format = "tar.zst"
+	Zip    Format = "zip"
+)
+
+var Formats = []Format{TarGz, TarZst, Zip}
+
+// ParseFormat validates an archive format name as given on the command line.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported archive format %q, must be one of %v", name, Formats)
+}
+
+// FormatFromName guesses the archive format from the extension of a file name.
+func FormatFromName(name string) (Format, bool) {
+	for _, format := range Formats {
+		if strings.HasSuffix(name, format.Extension()) {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Digest records the size and SHA-256 hash of a file written to an archive.
+type Digest struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// Writer writes files and directories into an archive of the given format.
+type Writer struct {
+	tw         *tar.Writer
+	zw         *zip.Writer
+	compressor io.WriteCloser
+	digests    []Digest
+}
+
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	switch format {
+	case TarGz:
+		gw := gzip.NewWriter(w)
+		return &Writer{tw: tar.NewWriter(gw), compressor: gw}, nil
+	case TarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &Writer{tw: tar.NewWriter(zw), compressor: zw}, nil
+	case Zip:
+		return &Writer{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+func (w *Writer) WriteDir(name string, modTime time.Time) error {
+	name = strings.TrimSuffix(filepath.ToSlash(name), "/") + "/"
+	if w.zw != nil {
+		_, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Modified: modTime})
+		return err
+	}
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name,
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+// WriteFile adds a regular file with the given size, copying its content from r.
+func (w *Writer) WriteFile(name string, size int64, modTime time.Time, r io.Reader) error {
+	name = filepath.ToSlash(name)
+	var fw io.Writer
+	if w.zw != nil {
+		var err error
+		fw, err = w.zw.CreateHeader(&zip.FileHeader{Name: name, Modified: modTime, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := w.tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  modTime,
+		})
+		if err != nil {
+			return err
+		}
+		fw = w.tw
+	}
+
+	hash := sha256.New()
+	written, err := io.Copy(fw, io.TeeReader(r, hash))
+	if err != nil {
+		return err
+	}
+	w.digests = append(w.digests, Digest{Name: name, Size: written, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+// Digests returns the digests of the files written so far, in writing order.
+func (w *Writer) Digests() []Digest {
+	return w.digests
+}
+
+// Close flushes the archive and its compression layer, without closing the underlying writer.
+func (w *Writer) Close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return errors.Join(w.tw.Close(), w.compressor.Close())
+}
+
+// Walk calls fn for each regular file of the archive at path, whose format is detected from its content.
+func Walk(path string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err = io.ReadFull(file, magic); err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.Equal(magic[:2], gzipMagic):
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		return walkTar(gr, fn)
+	case bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return walkTar(zr, fn)
+	case bytes.Equal(magic, zipMagic):
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return err
+		}
+		for _, entry := range zr.File {
+			if entry.FileInfo().IsDir() {
+				continue
+			}
+			content, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			err = errors.Join(fn(entry.Name, content), content.Close())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s is not a tar.gz, tar.zst or zip archive, it may need to be decrypted first", path)
+	}
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte{0x50, 0x4b, 0x03, 0x04}
+)
+
+func walkTar(r io.Reader, fn func(name string, r io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = fn(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteFileAtomic writes a file through a temporary file in the same directory, which is
+// renamed to its final name only once write has succeeded.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	return WriteFileVerified(path, write, nil)
+}
+
+// WriteFileVerified is WriteFileAtomic with a verification of the temporary file once it is
+// written and closed: the file gets its final name only when verify succeeds.
+func WriteFileVerified(path string, write func(w io.Writer) error, verify func(tempPath string) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	err = errors.Join(err, file.Close())
+	if err == nil && verify != nil {
+		err = verify(file.Name())
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
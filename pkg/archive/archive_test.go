@@ -0,0 +1,75 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package archive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileVerified(t *testing.T) {
+	tests := []struct {
+		name     string
+		writeErr error
+		verified error
+	}{
+		{name: "verified"},
+		{name: "write failure", writeErr: errors.New("disk full")},
+		{name: "verification failure", verified: errors.New("corrupted")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "nic-supportpkg.tar.gz")
+			var verifiedPath string
+			err := WriteFileVerified(path, func(w io.Writer) error {
+				_, err := io.WriteString(w, "content")
+				return errors.Join(err, test.writeErr)
+			}, func(tempPath string) error {
+				verifiedPath = tempPath
+				content, err := os.ReadFile(tempPath)
+				if err == nil && string(content) != "content" {
+					t.Errorf("unexpected content %q", content)
+				}
+				return errors.Join(err, test.verified)
+			})
+
+			wantErr := test.writeErr != nil || test.verified != nil
+			if wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.writeErr != nil && verifiedPath != "" {
+				t.Error("verify called after a failed write")
+			}
+			if test.writeErr == nil && (verifiedPath == "" || verifiedPath == path) {
+				t.Errorf("verify called with %q, expected the temporary file", verifiedPath)
+			}
+			entries, _ := os.ReadDir(dir)
+			if wantErr && len(entries) != 0 {
+				t.Errorf("files left after the failure: %v", entries)
+			}
+			if !wantErr && (len(entries) != 1 || entries[0].Name() != filepath.Base(path)) {
+				t.Errorf("unexpected files %v", entries)
+			}
+		})
+	}
+}
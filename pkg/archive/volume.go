@@ -0,0 +1,174 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IndexExtension is appended to the name of a split file to name its index.
+const IndexExtension = ".index.json"
+
+// VolumeIndex describes how a file was split into volumes, to reassemble and validate it.
+type VolumeIndex struct {
+	Name    string   `json:"name"`
+	Size    int64    `json:"size"`
+	SHA256  string   `json:"sha256"`
+	Volumes []Volume `json:"volumes"`
+}
+
+// Volume is one numbered part of a split file, named after it with a .001, .002... suffix.
+type Volume struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+var volumeSuffix = regexp.MustCompile(`\.[0-9]{3,}$`)
+
+// Split cuts the file at path into numbered volumes of at most maxSize bytes, next to it, and
+// writes their index. The original file is removed once all volumes are written. It returns
+// the path of the index followed by the paths of the volumes.
+func Split(path string, maxSize int64) ([]string, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid volume size %d", maxSize)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	index := VolumeIndex{Name: filepath.Base(path), Size: info.Size()}
+	var volumePaths []string
+	total := sha256.New()
+	remaining := info.Size()
+	for number := 1; remaining > 0 || number == 1; number++ {
+		volumeSize := min(remaining, maxSize)
+		volumePath := fmt.Sprintf("%s.%03d", path, number)
+		hash := sha256.New()
+		err = WriteFileAtomic(volumePath, func(w io.Writer) error {
+			_, err := io.CopyN(io.MultiWriter(w, hash, total), file, volumeSize)
+			return err
+		})
+		if err != nil {
+			removeAll(volumePaths)
+			return nil, err
+		}
+		volumePaths = append(volumePaths, volumePath)
+		index.Volumes = append(index.Volumes, Volume{Name: filepath.Base(volumePath), Size: volumeSize, SHA256: hex.EncodeToString(hash.Sum(nil))})
+		remaining -= volumeSize
+	}
+	index.SHA256 = hex.EncodeToString(total.Sum(nil))
+
+	indexPath := path + IndexExtension
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err == nil {
+		err = WriteFileAtomic(indexPath, func(w io.Writer) error {
+			_, err := w.Write(indexBytes)
+			return err
+		})
+	}
+	if err != nil {
+		removeAll(volumePaths)
+		return nil, err
+	}
+	_ = file.Close()
+	if err = os.Remove(path); err != nil {
+		return nil, err
+	}
+	return append([]string{indexPath}, volumePaths...), nil
+}
+
+// IndexPath returns the path of the index for the path of either the index or one of the volumes.
+func IndexPath(path string) string {
+	if strings.HasSuffix(path, IndexExtension) {
+		return path
+	}
+	return volumeSuffix.ReplaceAllString(path, "") + IndexExtension
+}
+
+// Join reassembles the volumes listed in the index at indexPath into outputPath, checking the
+// size and hash of every volume and of the reassembled file. An empty outputPath writes the
+// file under its original name, next to the index.
+func Join(indexPath string, outputPath string) (string, error) {
+	indexBytes, err := os.ReadFile(indexPath)
+	if err != nil {
+		return "", err
+	}
+	var index VolumeIndex
+	if err = json.Unmarshal(indexBytes, &index); err != nil {
+		return "", fmt.Errorf("invalid volume index %s: %w", indexPath, err)
+	}
+	if outputPath == "" {
+		outputPath = filepath.Join(filepath.Dir(indexPath), filepath.Base(index.Name))
+	}
+
+	err = WriteFileAtomic(outputPath, func(w io.Writer) error {
+		total := sha256.New()
+		var size int64
+		for _, volume := range index.Volumes {
+			written, err := copyVolume(io.MultiWriter(w, total), filepath.Join(filepath.Dir(indexPath), filepath.Base(volume.Name)), volume)
+			if err != nil {
+				return err
+			}
+			size += written
+		}
+		if size != index.Size || hex.EncodeToString(total.Sum(nil)) != index.SHA256 {
+			return fmt.Errorf("the reassembled %s does not match its index", index.Name)
+		}
+		return nil
+	})
+	return outputPath, err
+}
+
+func copyVolume(w io.Writer, path string, volume Volume) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("missing volume: %w", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(w, hash), file)
+	if err != nil {
+		return written, err
+	}
+	if written != volume.Size || hex.EncodeToString(hash.Sum(nil)) != volume.SHA256 {
+		return written, fmt.Errorf("volume %s is corrupted or incomplete", volume.Name)
+	}
+	return written, nil
+}
+
+func removeAll(paths []string) {
+	for _, path := range paths {
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,218 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// splitFile writes content to a file of a new directory and splits it into volumes.
+func splitFile(t *testing.T, content string, maxSize int64) (string, []string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nic-supportpkg-1711384966.tar.gz")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := Split(path, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, paths
+}
+
+func TestSplitJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		maxSize int64
+		sizes   []int64
+	}{
+		{"several volumes", "0123456789", 4, []int64{4, 4, 2}},
+		{"exact multiple", "01234567", 4, []int64{4, 4}},
+		{"single volume", "0123456789", 100, []int64{10}},
+		{"empty file", "", 4, []int64{0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, paths := splitFile(t, test.content, test.maxSize)
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("the split file is still there: %v", err)
+			}
+			if paths[0] != path+IndexExtension || len(paths) != len(test.sizes)+1 {
+				t.Fatalf("unexpected paths %v", paths)
+			}
+			for i, volumePath := range paths[1:] {
+				info, err := os.Stat(volumePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Size() != test.sizes[i] {
+					t.Errorf("volume %s has %d bytes, expected %d", volumePath, info.Size(), test.sizes[i])
+				}
+				if IndexPath(volumePath) != paths[0] {
+					t.Errorf("index of %s is %s", volumePath, IndexPath(volumePath))
+				}
+			}
+
+			joined, err := Join(IndexPath(paths[len(paths)-1]), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if joined != path {
+				t.Errorf("joined into %s, expected %s", joined, path)
+			}
+			content, err := os.ReadFile(joined)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != test.content {
+				t.Errorf("joined %q, expected %q", content, test.content)
+			}
+		})
+	}
+}
+
+func TestSplitInvalidSize(t *testing.T) {
+	if _, err := Split(filepath.Join(t.TempDir(), "missing.tar.gz"), 0); err == nil {
+		t.Error("expected an error for a volume size of 0")
+	}
+}
+
+func TestJoinCorrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, indexPath string, volumePaths []string)
+		wantErr string
+	}{
+		{
+			name: "missing volume",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				if err := os.Remove(volumePaths[1]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "missing volume",
+		},
+		{
+			name: "truncated volume",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				if err := os.Truncate(volumePaths[1], 2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "corrupted or incomplete",
+		},
+		{
+			name: "modified volume",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				if err := os.WriteFile(volumePaths[0], []byte("abcd"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "corrupted or incomplete",
+		},
+		{
+			name: "swapped volume files",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				first, second := volumePaths[0], volumePaths[1]
+				if err := os.Rename(first, first+".tmp"); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(second, first); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(first+".tmp", second); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "corrupted or incomplete",
+		},
+		{
+			name: "reordered index",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				updateIndex(t, indexPath, func(index *VolumeIndex) {
+					index.Volumes[0], index.Volumes[1] = index.Volumes[1], index.Volumes[0]
+				})
+			},
+			wantErr: "does not match its index",
+		},
+		{
+			name: "dropped volume",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				updateIndex(t, indexPath, func(index *VolumeIndex) {
+					index.Volumes = index.Volumes[:2]
+				})
+			},
+			wantErr: "does not match its index",
+		},
+		{
+			name: "invalid index",
+			corrupt: func(t *testing.T, indexPath string, volumePaths []string) {
+				if err := os.WriteFile(indexPath, []byte("{"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "invalid volume index",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, paths := splitFile(t, "0123456789", 4)
+			test.corrupt(t, paths[0], paths[1:])
+
+			_, err := Join(paths[0], "")
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("error %v, expected one with %q", err, test.wantErr)
+			}
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if slices.ContainsFunc(entries, func(entry os.DirEntry) bool {
+				return entry.Name() == filepath.Base(path) || strings.Contains(entry.Name(), ".tmp-")
+			}) {
+				t.Errorf("output left after the failed join: %v", entries)
+			}
+		})
+	}
+}
+
+func updateIndex(t *testing.T, indexPath string, update func(index *VolumeIndex)) {
+	t.Helper()
+	indexBytes, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index VolumeIndex
+	if err = json.Unmarshal(indexBytes, &index); err != nil {
+		t.Fatal(err)
+	}
+	update(&index)
+	if indexBytes, err = json.Marshal(index); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(indexPath, indexBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,10 +18,19 @@ limitations under the License.
 
 package crds
 
+import (
+	"slices"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const GatewayAPIGroup = "gateway.networking.k8s.io"
+
 type Crd struct {
-	Resource string
-	Group    string
-	Version  string
+	Resource      string
+	Group         string
+	Version       string
+	ClusterScoped bool
 }
 
 func GetNICCRDList() []Crd {
@@ -29,57 +38,46 @@ func GetNICCRDList() []Crd {
 		{
 			Resource: "apdoslogconfs",
 			Group:    "appprotectdos.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "apdospolicies",
 			Group:    "appprotectdos.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "dosprotectedresources",
 			Group:    "appprotectdos.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "aplogconfs",
 			Group:    "appprotect.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "appolicies",
 			Group:    "appprotect.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "apusersigs",
 			Group:    "appprotect.f5.com",
-			Version:  "v1beta1",
 		},
 		{
 			Resource: "globalconfigurations",
 			Group:    "k8s.nginx.org",
-			Version:  "v1",
 		},
 		{
 			Resource: "policies",
 			Group:    "k8s.nginx.org",
-			Version:  "v1",
 		},
 		{
 			Resource: "transportservers",
 			Group:    "k8s.nginx.org",
-			Version:  "v1",
 		},
 		{
 			Resource: "virtualserverroutes",
 			Group:    "k8s.nginx.org",
-			Version:  "v1",
 		},
 		{
 			Resource: "virtualservers",
 			Group:    "k8s.nginx.org",
-			Version:  "v1",
 		},
 	}
 	return crdList
@@ -90,23 +88,74 @@ func GetNGFCRDList() []Crd {
 		{
 			Resource: "clientsettingspolicies",
 			Group:    "gateway.nginx.org",
-			Version:  "v1alpha1",
 		},
 		{
 			Resource: "nginxgateways",
 			Group:    "gateway.nginx.org",
-			Version:  "v1alpha1",
 		},
 		{
 			Resource: "nginxproxies",
 			Group:    "gateway.nginx.org",
-			Version:  "v1alpha1",
 		},
 		{
 			Resource: "observabilitypolicies",
 			Group:    "gateway.nginx.org",
-			Version:  "v1alpha1",
 		},
 	}
 	return crdList
 }
+
+// GetGatewayAPICRDList returns every Gateway API resource found in the given CRD definitions.
+func GetGatewayAPICRDList(definitions []apiextensionsv1.CustomResourceDefinition) []Crd {
+	var crdList []Crd
+	for _, definition := range definitions {
+		if definition.Spec.Group == GatewayAPIGroup {
+			crdList = append(crdList, Crd{
+				Resource: definition.Spec.Names.Plural,
+				Group:    definition.Spec.Group,
+			})
+		}
+	}
+	return crdList
+}
+
+// Resolve fills in the version and scope of each CRD from the definitions installed in the cluster.
+// The storage version is used unless allVersions is set, in which case one entry is returned per
+// served version. CRDs without a matching definition are returned as not installed.
+func Resolve(crdList []Crd, definitions []apiextensionsv1.CustomResourceDefinition, allVersions bool) (resolved []Crd, notInstalled []Crd) {
+	for _, crd := range crdList {
+		index := slices.IndexFunc(definitions, func(definition apiextensionsv1.CustomResourceDefinition) bool {
+			return definition.Spec.Group == crd.Group && definition.Spec.Names.Plural == crd.Resource
+		})
+		if index < 0 {
+			notInstalled = append(notInstalled, crd)
+			continue
+		}
+		definition := definitions[index]
+		crd.ClusterScoped = definition.Spec.Scope == apiextensionsv1.ClusterScoped
+
+		var versions []string
+		for _, version := range definition.Spec.Versions {
+			if !version.Served {
+				continue
+			}
+			if allVersions {
+				versions = append(versions, version.Name)
+			} else if version.Storage {
+				versions = []string{version.Name}
+				break
+			} else if len(versions) == 0 {
+				versions = []string{version.Name}
+			}
+		}
+		if len(versions) == 0 {
+			notInstalled = append(notInstalled, crd)
+			continue
+		}
+		for _, version := range versions {
+			crd.Version = version
+			resolved = append(resolved, crd)
+		}
+	}
+	return resolved, notInstalled
+}
@@ -0,0 +1,116 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package data_collector
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+)
+
+const (
+	CheckpointFileName = "checkpoint.json"
+	workDirFiles       = "files"
+)
+
+// Checkpoint records the progress of a collection staged in a work directory.
+type Checkpoint struct {
+	Product    string    `json:"product"`
+	Namespaces []string  `json:"namespaces"`
+	Started    time.Time `json:"started"`
+	// Completed lists the jobs whose files are staged, which are not run again when resuming
+	Completed []string `json:"completed"`
+}
+
+// LoadCheckpoint reads the checkpoint of the work directory, or returns nil when there is none.
+func (c *DataCollector) LoadCheckpoint() (*Checkpoint, error) {
+	checkpointBytes, err := os.ReadFile(filepath.Join(c.WorkDir, CheckpointFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err = json.Unmarshal(checkpointBytes, checkpoint); err != nil {
+		return nil, errors.New("invalid " + CheckpointFileName + ": " + err.Error())
+	}
+	return checkpoint, nil
+}
+
+// StartCheckpoint starts recording the progress of the collection in the work directory, from an
+// earlier checkpoint when resuming.
+func (c *DataCollector) StartCheckpoint(checkpoint Checkpoint) error {
+	c.checkpointMu.Lock()
+	defer c.checkpointMu.Unlock()
+	c.checkpoint = &checkpoint
+	return c.saveCheckpoint()
+}
+
+// MarkCompleted records that the files of a job are staged. It does nothing without a work directory.
+func (c *DataCollector) MarkCompleted(job string) error {
+	c.checkpointMu.Lock()
+	defer c.checkpointMu.Unlock()
+	if c.checkpoint == nil || slices.Contains(c.checkpoint.Completed, job) {
+		return nil
+	}
+	c.checkpoint.Completed = append(c.checkpoint.Completed, job)
+	return c.saveCheckpoint()
+}
+
+func (c *DataCollector) saveCheckpoint() error {
+	checkpointBytes, err := json.MarshalIndent(c.checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return archive.WriteFileAtomic(filepath.Join(c.WorkDir, CheckpointFileName), func(w io.Writer) error {
+		_, err := w.Write(checkpointBytes)
+		return err
+	})
+}
+
+// cleanUp removes the staged files once the package is written, along with the checkpoint,
+// unless they are kept to resume the collection.
+func (c *DataCollector) cleanUp() {
+	if c.KeepFiles {
+		return
+	}
+	_ = os.RemoveAll(c.BaseDir)
+	if c.WorkDir != "" {
+		_ = os.Remove(filepath.Join(c.WorkDir, CheckpointFileName))
+	}
+}
+
+// Discard releases a collector whose collection ends before WrapUp, removing its temporary
+// directory. The files of a work directory are kept, as they may belong to an interrupted collection.
+func (c *DataCollector) Discard() {
+	if c.LogFile != nil {
+		_ = c.LogFile.Close()
+	}
+	if c.WorkDir == "" {
+		_ = os.RemoveAll(c.BaseDir)
+	}
+}
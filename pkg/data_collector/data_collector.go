@@ -19,55 +19,140 @@ limitations under the License.
 package data_collector
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	helmClient "github.com/mittwald/go-helm-client"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/encrypt"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/retry"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/throttle"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/version"
 	"io"
-	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	crdClient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/remotecommand"
 	"k8s.io/client-go/util/homedir"
 	metricsClient "k8s.io/metrics/pkg/client/clientset/versioned"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 )
 
+const DefaultNameTemplate = "{{.Product}}-supportpkg-{{.Unix}}"
+
+// StreamOutput is the output path that streams the archive to stdout.
+const StreamOutput = "-"
+
+// OutputOptions control where the support package is written and in which format.
+type OutputOptions struct {
+	// Path is the full path of the archive, or StreamOutput, and takes precedence over Dir and NameTemplate
+	Path         string
+	Dir          string
+	NameTemplate string
+	Format       archive.Format
+	// Recipient, when set, encrypts the archive as it is written
+	Recipient encrypt.Recipient
+	// SigningKey, when set, signs the manifest of the archive
+	SigningKey ed25519.PrivateKey
+}
+
+// NameTemplateData holds the fields available to OutputOptions.NameTemplate.
+type NameTemplateData struct {
+	Product   string
+	Cluster   string
+	Context   string
+	Timestamp string
+	Unix      int64
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
 type DataCollector struct {
-	BaseDir             string
-	Namespaces          []string
-	Logger              *log.Logger
+	BaseDir     string
+	Namespaces  []string
+	StartTime   time.Time
+	KubeContext string
+	KubeCluster string
+	Output      OutputOptions
+	// Logger writes JSON records to supportpkg.log, with the job of the context passed to its *Context methods
+	Logger              *slog.Logger
 	LogFile             *os.File
 	K8sRestConfig       *rest.Config
-	K8sCoreClientSet    *kubernetes.Clientset
-	K8sCrdClientSet     *crdClient.Clientset
-	K8sMetricsClientSet *metricsClient.Clientset
+	K8sCoreClientSet    kubernetes.Interface
+	K8sCrdClientSet     crdClient.Interface
+	K8sMetricsClientSet metricsClient.Interface
+	K8sDynamicClient    dynamic.Interface
 	K8sHelmClientSet    map[string]helmClient.Client
+	K8sExecutor         CommandExecutor
+	AllCRDVersions      bool
+	AllNamespaces       bool
+	// KubeConfig is the path of the kubeconfig file, empty when the collector was created from a rest config
+	KubeConfig string
+	// WorkDir holds the staged files and the checkpoint of a resumable collection, it is empty otherwise
+	WorkDir string
+	// KeepFiles keeps the staged files once the package is written, to resume an interrupted collection
+	KeepFiles bool
+	// ProductPods are the pods of the product found before collecting, as namespace/name
+	ProductPods []string
+	// Retry is the policy for failed API requests and pod executions
+	Retry retry.Policy
+	// Throttle limits the rate of all requests to the API server
+	Throttle *throttle.Limiter
+	// Progress receives the events of the jobs, it is called concurrently when jobs run in parallel
+	Progress func(progress.Event)
+
+	logHandler slog.Handler
+
+	checkpoint   *Checkpoint
+	checkpointMu sync.Mutex
+
+	// attempts records the failed attempts of requests, for the manifest
+	attempts   []manifest.RetryAttempt
+	attemptsMu sync.Mutex
+	// collectorRuns records the runs of external collectors, for the manifest
+	collectorRuns   []manifest.CollectorRun
+	collectorRunsMu sync.Mutex
+	// definitions caches the CRDs of the cluster, listed once for all the jobs needing them
+	definitions   *apiextensionsv1.CustomResourceDefinitionList
+	definitionsMu sync.Mutex
+
+	// stream is set while the archive is streamed, files then go straight into it instead of BaseDir
+	stream     *archive.Writer
+	streamEnc  io.WriteCloser
+	streamRoot string
+	streamDirs map[string]bool
+	streamMu   sync.Mutex
 }
 
 func NewDataCollector(namespaces ...string) (*DataCollector, error) {
+	return NewDataCollectorInDir("", namespaces...)
+}
 
-	tmpDir, err := os.MkdirTemp("", "-pkg-diag")
-	if err != nil {
-		return nil, fmt.Errorf("unable to create temp directory: %s", err)
-	}
-
-	logFile, err := os.OpenFile(filepath.Join(tmpDir, "supportpkg.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create log file: %s", err)
-	}
+// NewDataCollectorInDir stages the collected files in the files directory of workDir, next to the
+// checkpoint of the collection, so that they survive an interruption. An empty workDir stages them
+// in a temporary directory instead.
+func NewDataCollectorInDir(workDir string, namespaces ...string) (*DataCollector, error) {
 
 	// Find config
 	kubeConfig := os.Getenv("KUBECONFIG")
@@ -80,19 +165,91 @@ func NewDataCollector(namespaces ...string) (*DataCollector, error) {
 		return nil, fmt.Errorf("unable to connect to k8s using file %s: %s", kubeConfig, err)
 	}
 
+	dc, err := NewDataCollectorForConfig(config, workDir, namespaces...)
+	if err != nil {
+		return nil, err
+	}
+
+	dc.KubeConfig = kubeConfig
+	// The context and cluster names are informational, used in the package name only
+	if rawConfig, err := clientcmd.LoadFromFile(kubeConfig); err == nil {
+		dc.KubeContext = rawConfig.CurrentContext
+		if kubeContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+			dc.KubeCluster = kubeContext.Cluster
+		}
+	}
+	return dc, nil
+}
+
+// NewDataCollectorForConfig connects to the cluster of config rather than to the one of the
+// kubeconfig file, staging the collected files like NewDataCollectorInDir. The config is copied
+// before the clients are created from it.
+func NewDataCollectorForConfig(config *rest.Config, workDir string, namespaces ...string) (*DataCollector, error) {
+
+	var tmpDir string
+	var err error
+	if workDir == "" {
+		tmpDir, err = os.MkdirTemp("", "-pkg-diag")
+	} else {
+		tmpDir = filepath.Join(workDir, workDirFiles)
+		err = os.MkdirAll(tmpDir, os.ModePerm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temp directory: %s", err)
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(tmpDir, "supportpkg.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create log file: %s", err)
+	}
+	fileHandler := slog.NewJSONHandler(logFile, &slog.HandlerOptions{Level: slog.LevelDebug})
+
 	dc := DataCollector{
 		BaseDir:          tmpDir,
+		WorkDir:          workDir,
 		Namespaces:       namespaces,
+		StartTime:        time.Now(),
+		Output:           OutputOptions{Dir: ".", NameTemplate: DefaultNameTemplate, Format: archive.TarGz},
 		LogFile:          logFile,
-		Logger:           log.New(logFile, "", log.LstdFlags|log.LUTC|log.Lmicroseconds|log.Lshortfile),
+		Logger:           slog.New(jobHandler{fileHandler}),
+		logHandler:       fileHandler,
 		K8sHelmClientSet: make(map[string]helmClient.Client),
+		Retry:            retry.DefaultPolicy,
+		Throttle:         throttle.NewLimiter(throttle.DefaultQPS, throttle.DefaultBurst),
 	}
 
+	// Requests are rate limited by Throttle rather than by each client, so that the limit is shared
+	// by all clients and applies to each retry, as the first wrapper is the closest to the network
+	config = rest.CopyConfig(config)
+	config.UserAgent = UserAgent()
+	config.QPS = -1
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &throttle.Transport{
+			Next:    next,
+			Limiter: dc.Throttle,
+			OnThrottled: func(request *http.Request, qps float64) {
+				dc.Logger.WarnContext(request.Context(), "API server is throttling requests, slowing down", "qps", qps)
+			},
+		}
+	})
+	// Idempotent API requests are retried, including those of the clients created from the config
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &retry.Transport{
+			Next:   next,
+			Policy: func() retry.Policy { return dc.Retry },
+			OnFailure: func(request *http.Request, attempt retry.Attempt) {
+				dc.recordAttempt(request.Context(), request.Method+" "+request.URL.Path, attempt)
+			},
+		}
+	})
+
 	//Initialize clients
 	dc.K8sRestConfig = config
 	dc.K8sCoreClientSet, _ = kubernetes.NewForConfig(config)
 	dc.K8sCrdClientSet, _ = crdClient.NewForConfig(config)
 	dc.K8sMetricsClientSet, _ = metricsClient.NewForConfig(config)
+	dc.K8sDynamicClient, _ = dynamic.NewForConfig(config)
+	dc.K8sExecutor = &spdyExecutor{coreClientSet: dc.K8sCoreClientSet, config: config}
 	for _, namespace := range dc.Namespaces {
 		dc.K8sHelmClientSet[namespace], _ = helmClient.NewClientFromRestConf(&helmClient.RestConfClientOptions{
 			Options:    &helmClient.Options{Namespace: namespace},
@@ -103,57 +260,235 @@ func NewDataCollector(namespaces ...string) (*DataCollector, error) {
 	return &dc, nil
 }
 
-func (c *DataCollector) WrapUp(product string) (string, error) {
+// BundleName renders the name of the support package, without extension, from the name template.
+func (c *DataCollector) BundleName(product string) (string, error) {
+	nameTemplate, err := template.New("name").Option("missingkey=error").Parse(c.Output.NameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+	data := NameTemplateData{
+		Product:   product,
+		Cluster:   unsafeNameChars.ReplaceAllString(c.KubeCluster, "_"),
+		Context:   unsafeNameChars.ReplaceAllString(c.KubeContext, "_"),
+		Timestamp: c.StartTime.UTC().Format(time.RFC3339),
+		Unix:      c.StartTime.Unix(),
+	}
+	var name strings.Builder
+	if err = nameTemplate.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+	if name.Len() == 0 || strings.ContainsAny(name.String(), `/\`) {
+		return "", fmt.Errorf("invalid name template: %q is not a valid file name", name.String())
+	}
+	return name.String(), nil
+}
 
-	unixTime := time.Now().Unix()
-	unixTimeString := strconv.FormatInt(unixTime, 10)
-	tarballName := fmt.Sprintf("%s-supportpkg-%s.tar.gz", product, unixTimeString)
-	tarballRootDirName := fmt.Sprintf("%s-supportpkg-%s", product, unixTimeString)
+// OutputPath returns the path of the archive and the name of its root directory. An explicit path
+// named like an archive of another format than the one written is rejected.
+func (c *DataCollector) OutputPath(product string) (string, string, error) {
+	if c.Output.Path != "" && c.Output.Path != StreamOutput {
+		rootDirName := filepath.Base(c.Output.Path)
+		if c.Output.Recipient != nil {
+			rootDirName = strings.TrimSuffix(rootDirName, c.Output.Recipient.Extension())
+		}
+		if format, ok := archive.FormatFromName(rootDirName); ok && format != c.Output.Format {
+			return "", "", fmt.Errorf("output %s is named like a %s archive but the format is %s", c.Output.Path, format, c.Output.Format)
+		}
+		rootDirName = strings.TrimSuffix(rootDirName, c.Output.Format.Extension())
+		return c.Output.Path, rootDirName, nil
+	}
+	name, err := c.BundleName(product)
+	if err != nil {
+		return "", "", err
+	}
+	if c.Output.Path == StreamOutput {
+		return StreamOutput, name, nil
+	}
+	extension := c.Output.Format.Extension()
+	if c.Output.Recipient != nil {
+		extension += c.Output.Recipient.Extension()
+	}
+	return filepath.Join(c.Output.Dir, name+extension), name, nil
+}
+
+// encryptWriter wraps w to encrypt the archive when a recipient is configured.
+func (c *DataCollector) encryptWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.Output.Recipient == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return c.Output.Recipient.Encrypt(w)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
 
-	err := c.LogFile.Close()
+// StartStream starts writing the archive to w. From then on, WriteFile adds files to the
+// archive as they are collected and WrapUp only adds the log file before closing it.
+func (c *DataCollector) StartStream(product string, w io.Writer) error {
+	_, rootDirName, err := c.OutputPath(product)
+	if err != nil {
+		return err
+	}
+	ew, err := c.encryptWriter(w)
 	if err != nil {
-		return tarballName, err
+		return err
 	}
+	aw, err := archive.NewWriter(ew, c.Output.Format)
+	if err != nil {
+		return err
+	}
+	if err = aw.WriteDir(rootDirName, c.StartTime); err != nil {
+		return err
+	}
+	c.stream = aw
+	c.streamEnc = ew
+	c.streamRoot = rootDirName
+	c.streamDirs = map[string]bool{rootDirName: true}
+	return nil
+}
 
-	file, err := os.Create(tarballName)
+// WriteFile stores a collected file, either in BaseDir or, when streaming, directly in the archive.
+// The path is expected to be located under BaseDir.
+func (c *DataCollector) WriteFile(path string, data []byte) error {
+	if c.stream == nil {
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("MkdirAll failed: %v", err)
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	relativePath, err := filepath.Rel(c.BaseDir, path)
 	if err != nil {
-		return tarballName, err
-	}
-	defer func(file *os.File) {
-		cerr := file.Close()
-		if cerr == nil {
-			err = cerr
-		} else {
-			c.Logger.Printf("error closing file %s, %v", file.Name(), cerr)
+		return err
+	}
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	return c.streamFile(relativePath, int64(len(data)), time.Now(), bytes.NewReader(data))
+}
+
+// streamFile writes a file into the streamed archive, preceded by any of its parent directories not written yet.
+func (c *DataCollector) streamFile(relativePath string, size int64, modTime time.Time, r io.Reader) error {
+	dir := c.streamRoot
+	for _, part := range strings.Split(filepath.Dir(relativePath), string(filepath.Separator)) {
+		if part == "." {
+			continue
 		}
-	}(file)
-
-	gw := gzip.NewWriter(file)
-	defer func(gw *gzip.Writer) {
-		cerr := gw.Close()
-		if cerr == nil {
-			err = cerr
-		} else {
-			c.Logger.Printf("error closing gzip writer, %v", cerr)
+		dir = filepath.Join(dir, part)
+		if !c.streamDirs[dir] {
+			if err := c.stream.WriteDir(dir, modTime); err != nil {
+				return err
+			}
+			c.streamDirs[dir] = true
+		}
+	}
+	return c.stream.WriteFile(filepath.Join(c.streamRoot, relativePath), size, modTime, r)
+}
+
+func (c *DataCollector) WrapUp(product string) (string, error) {
+
+	archivePath, rootDirName, err := c.OutputPath(product)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.LogFile.Close()
+	if err != nil {
+		return archivePath, err
+	}
+
+	if c.stream != nil {
+		c.streamMu.Lock()
+		defer c.streamMu.Unlock()
+		err = c.streamBaseDir()
+		if err == nil {
+			err = c.writeManifest(c.stream, c.streamRoot, product)
 		}
-	}(gw)
-
-	tw := tar.NewWriter(gw)
-	defer func(tw *tar.Writer) {
-		cerr := tw.Close()
-		if cerr == nil {
-			err = cerr
-		} else {
-			c.Logger.Printf("error closing tar writer, %v", cerr)
+		err = errors.Join(err, c.stream.Close(), c.streamEnc.Close())
+		if err != nil {
+			return archivePath, err
 		}
-	}(tw)
+		c.cleanUp()
+		return archivePath, nil
+	}
 
-	err = filepath.Walk(c.BaseDir, func(path string, info os.FileInfo, err error) error {
+	err = os.MkdirAll(filepath.Dir(archivePath), os.ModePerm)
+	if err != nil {
+		return archivePath, err
+	}
+
+	// The archive is verified before it gets its final name, so that a corrupted archive is never
+	// left next to the files kept for the incomplete package
+	written := sha256.New()
+	err = archive.WriteFileVerified(archivePath, func(w io.Writer) error {
+		ew, err := c.encryptWriter(io.MultiWriter(w, written))
 		if err != nil {
 			return err
 		}
+		aw, err := archive.NewWriter(ew, c.Output.Format)
+		if err != nil {
+			return errors.Join(err, ew.Close())
+		}
+		err = c.archiveBaseDir(aw, rootDirName)
+		if err == nil {
+			err = c.writeManifest(aw, rootDirName, product)
+		}
+		return errors.Join(err, aw.Close(), ew.Close())
+	}, func(tempPath string) error {
+		if err := c.verifyArchive(tempPath, written.Sum(nil)); err != nil {
+			return fmt.Errorf("verification of %s failed: %w", archivePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return archivePath, err
+	}
+	c.cleanUp()
+	return archivePath, nil
+}
+
+// verifyArchive re-reads the archive at path once written: its content must be the bytes that were written
+// and, unless it is encrypted, every file it holds must match its manifest.
+func (c *DataCollector) verifyArchive(path string, writtenHash []byte) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Sum(nil), writtenHash) {
+		return errors.New("the archive on disk differs from the archive that was written")
+	}
 
-		header, err := tar.FileInfoHeader(info, "")
+	if c.Output.Recipient != nil {
+		return nil
+	}
+	var publicKey ed25519.PublicKey
+	if c.Output.SigningKey != nil {
+		publicKey = c.Output.SigningKey.Public().(ed25519.PublicKey)
+	}
+	report, err := manifest.Verify(path, publicKey)
+	if err != nil {
+		return err
+	}
+	if !report.Valid() {
+		return fmt.Errorf("%d missing, %d modified and %d unexpected file(s)", len(report.Missing), len(report.Mismatched), len(report.Unexpected))
+	}
+	return nil
+}
+
+// archiveBaseDir adds the content of BaseDir to the archive, under rootDirName.
+func (c *DataCollector) archiveBaseDir(aw *archive.Writer, rootDirName string) error {
+	return filepath.Walk(c.BaseDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -162,107 +497,201 @@ func (c *DataCollector) WrapUp(product string) (string, error) {
 		if err != nil {
 			return err
 		}
-		header.Name = filepath.ToSlash(filepath.Join(tarballRootDirName, relativePath))
+		name := filepath.Join(rootDirName, relativePath)
 
-		if err = tw.WriteHeader(header); err != nil {
-			return err
+		if info.IsDir() {
+			return aw.WriteDir(name, info.ModTime())
 		}
-
 		if !info.Mode().IsRegular() {
 			return nil
 		}
 
-		file, err = os.Open(path)
+		file, err := os.Open(path)
 		if err != nil {
 			return err
 		}
-		defer func(file *os.File) {
-			cerr := file.Close()
-			if cerr == nil {
-				err = cerr
-			} else {
-				c.Logger.Printf("error closing file %s, %v", file.Name(), cerr)
-			}
-		}(file)
+		err = aw.WriteFile(name, info.Size(), info.ModTime(), file)
+		return errors.Join(err, file.Close())
+	})
+}
+
+// writeManifest adds the manifest of the files written so far to the archive, and its signature
+// when a signing key is configured.
+func (c *DataCollector) writeManifest(aw *archive.Writer, rootDirName string, product string) error {
+	bundleManifest := manifest.Manifest{
+		Version: version.Version,
+		Build:   version.Build,
+		Product: product,
+		Created: c.StartTime.UTC(),
+		Files:   []manifest.File{},
+	}
+	c.attemptsMu.Lock()
+	bundleManifest.Retries = slices.Clone(c.attempts)
+	c.attemptsMu.Unlock()
+	c.collectorRunsMu.Lock()
+	bundleManifest.Collectors = slices.Clone(c.collectorRuns)
+	c.collectorRunsMu.Unlock()
+	slices.SortFunc(bundleManifest.Collectors, func(a, b manifest.CollectorRun) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, digest := range aw.Digests() {
+		bundleManifest.Files = append(bundleManifest.Files, manifest.File{
+			Path:   strings.TrimPrefix(digest.Name, filepath.ToSlash(rootDirName)+"/"),
+			Size:   digest.Size,
+			SHA256: digest.SHA256,
+		})
+	}
+	slices.SortFunc(bundleManifest.Files, func(a, b manifest.File) int {
+		return strings.Compare(a.Path, b.Path)
+	})
 
-		_, err = io.Copy(tw, file)
+	manifestBytes, err := json.MarshalIndent(bundleManifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = aw.WriteFile(filepath.Join(rootDirName, manifest.FileName), int64(len(manifestBytes)), now, bytes.NewReader(manifestBytes))
+	if err != nil || c.Output.SigningKey == nil {
+		return err
+	}
+	signature, err := manifest.Sign(manifestBytes, c.Output.SigningKey)
+	if err != nil {
+		return err
+	}
+	return aw.WriteFile(filepath.Join(rootDirName, manifest.SignatureFileName), int64(len(signature)), now, bytes.NewReader(signature))
+}
+
+// streamBaseDir adds the files staged in BaseDir, such as the log file, to the streamed archive.
+func (c *DataCollector) streamBaseDir() error {
+	return filepath.Walk(c.BaseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		relativePath, err := filepath.Rel(c.BaseDir, path)
 		if err != nil {
 			return err
 		}
-
-		return nil
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = c.streamFile(relativePath, info.Size(), info.ModTime(), file)
+		return errors.Join(err, file.Close())
 	})
-	if err != nil {
-		return tarballName, err
+}
+
+// UserAgent identifies the requests of the tool to the API server, in its audit logs and to
+// API Priority and Fairness, with the version of the tool.
+func UserAgent() string {
+	return fmt.Sprintf("nginx-supportpkg/%s (%s/%s) build/%s", version.Version, runtime.GOOS, runtime.GOARCH, version.Build)
+}
+
+// Emit sends a progress event, if anything listens to them.
+func (c *DataCollector) Emit(event progress.Event) {
+	if c.Progress == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
 	}
-	_ = os.RemoveAll(c.BaseDir)
-	return tarballName, nil
+	c.Progress(event)
 }
 
+// ReportPod tells which pod the job running with ctx is working on.
+func (c *DataCollector) ReportPod(ctx context.Context, namespace string, pod string, container string) {
+	c.Emit(progress.Event{Type: progress.JobPod, Job: progress.JobFromContext(ctx), Detail: namespace + "/" + pod + "/" + container})
+}
+
+// RecordCollectorRun adds the run of an external collector to the manifest.
+func (c *DataCollector) RecordCollectorRun(run manifest.CollectorRun) {
+	c.collectorRunsMu.Lock()
+	defer c.collectorRunsMu.Unlock()
+	c.collectorRuns = append(c.collectorRuns, run)
+}
+
+// recordAttempt logs a failed attempt of a request and records it for the manifest.
+func (c *DataCollector) recordAttempt(ctx context.Context, operation string, attempt retry.Attempt) {
+	c.Logger.WarnContext(ctx, "Request failed", "operation", operation, "attempt", attempt.Number, "retryIn", attempt.Wait, "error", attempt.Err)
+	c.attemptsMu.Lock()
+	defer c.attemptsMu.Unlock()
+	c.attempts = append(c.attempts, manifest.RetryAttempt{
+		Job:         progress.JobFromContext(ctx),
+		Operation:   operation,
+		Attempt:     attempt.Number,
+		Error:       attempt.Err.Error(),
+		WaitSeconds: attempt.Wait.Seconds(),
+		Time:        time.Now().UTC(),
+	})
+}
+
+// PodExecutor runs a command in a container and returns its output, retrying when the execution
+// fails without output, for instance when the stream is reset.
 func (c *DataCollector) PodExecutor(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error) {
-	req := c.K8sCoreClientSet.CoreV1().RESTClient().Post().
-		Namespace(namespace).
-		Resource("pods").
-		Name(pod).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Command:   command,
-			Container: container,
-			Stdin:     false,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       true,
-		}, scheme.ParameterCodec)
-
-	exec, err := remotecommand.NewSPDYExecutor(c.K8sRestConfig, "POST", req.URL())
-	if err != nil {
-		return nil, err
-	}
-	var stdout, stderr bytes.Buffer
-	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdin:  nil,
-		Stdout: &stdout,
-		Stderr: &stderr,
+	c.ReportPod(ctx, namespace, pod, container)
+	var response []byte
+	err := retry.Do(ctx, c.Retry, func() error {
+		var err error
+		response, err = c.K8sExecutor.Exec(namespace, pod, container, command, ctx)
+		return err
+	}, func(attempt retry.Attempt) {
+		c.recordAttempt(ctx, fmt.Sprintf("exec %s/%s/%s", namespace, pod, container), attempt)
 	})
-	if stdout.Len() > 0 || stderr.Len() > 0 {
-		response := append(stdout.Bytes(), stderr.Bytes()...)
-		return response, nil
-	} else {
-		return nil, err
-	}
+	return response, err
 }
 
 func (c *DataCollector) QueryCRD(crd crds.Crd, namespace string, ctx context.Context) ([]byte, error) {
 
-	schemeGroupVersion := schema.GroupVersion{Group: crd.Group, Version: crd.Version}
-	negotiatedSerializer := scheme.Codecs.WithoutConversion()
-	c.K8sRestConfig.APIPath = "apis"
-	c.K8sRestConfig.GroupVersion = &schemeGroupVersion
-	c.K8sRestConfig.NegotiatedSerializer = negotiatedSerializer
+	resource := c.K8sDynamicClient.Resource(schema.GroupVersionResource{Group: crd.Group, Version: crd.Version, Resource: crd.Resource})
 
-	client, err := rest.RESTClientFor(c.K8sRestConfig)
+	// Cluster-scoped resources have no namespace, and an empty namespace lists namespaced ones across all namespaces
+	var client dynamic.ResourceInterface = resource
+	if !crd.ClusterScoped {
+		client = resource.Namespace(namespace)
+	}
+	result, err := client.List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
+	return result.MarshalJSON()
+}
 
-	result := client.Get().
-		Namespace(namespace). // Specify the namespace if needed
-		Resource(crd.Resource).
-		Do(ctx)
+// CRDDefinitions returns the CRDs of the cluster. They are listed on the first call only, the
+// schemas of large clusters weighing megabytes; a failed list is tried again on the next call.
+func (c *DataCollector) CRDDefinitions(ctx context.Context) (*apiextensionsv1.CustomResourceDefinitionList, error) {
+	c.definitionsMu.Lock()
+	defer c.definitionsMu.Unlock()
+	if c.definitions == nil {
+		definitions, err := c.K8sCrdClientSet.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		c.definitions = definitions
+	}
+	return c.definitions, nil
+}
 
-	return result.Raw()
+// ResolveCRDs looks up the given CRDs in the cluster to find out which versions to query.
+// CRDs that are not installed are returned separately instead of failing the lookup.
+func (c *DataCollector) ResolveCRDs(crdList []crds.Crd, allVersions bool, ctx context.Context) ([]crds.Crd, []crds.Crd, error) {
+	definitions, err := c.CRDDefinitions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, notInstalled := crds.Resolve(crdList, definitions.Items, allVersions)
+	return resolved, notInstalled, nil
 }
 
-func (c *DataCollector) AllNamespacesExist() bool {
-	var allExist = true
+// AllNamespacesExist checks every namespace of the collector can be retrieved, the error names
+// those that cannot.
+func (c *DataCollector) AllNamespacesExist(ctx context.Context) error {
+	var errs []error
 	for _, namespace := range c.Namespaces {
-		_, err := c.K8sCoreClientSet.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+		_, err := c.K8sCoreClientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
 		if err != nil {
-			c.Logger.Printf("\t%s: %v\n", namespace, err)
-			fmt.Printf("\t%s: %v\n", namespace, err)
-			allExist = false
+			c.Logger.Error("Could not retrieve namespace", "namespace", namespace, "error", err)
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespace, err))
 		}
 	}
 
-	return allExist
+	return errors.Join(errs...)
 }
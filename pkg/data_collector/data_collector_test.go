@@ -0,0 +1,225 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package data_collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/encrypt"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/retry"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestPodExecutor(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []error
+		wantErr  bool
+		attempts int
+	}{
+		{name: "success"},
+		{name: "transient failure", failures: []error{errors.New("stream reset")}, attempts: 1},
+		{name: "retries exhausted", failures: []error{errors.New("stream reset"), errors.New("stream reset"), errors.New("stream reset")}, wantErr: true, attempts: 3},
+		{name: "command failure", failures: []error{utilexec.CodeExitError{Err: errors.New("exit 1"), Code: 1}}, wantErr: true, attempts: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			dc := &DataCollector{
+				Logger: slog.New(slog.DiscardHandler),
+				Retry:  retry.Policy{Retries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+				K8sExecutor: ExecutorFunc(func(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error) {
+					calls++
+					if calls <= len(test.failures) {
+						return nil, test.failures[calls-1]
+					}
+					return []byte(strings.Join(command, " ")), nil
+				}),
+			}
+
+			output, err := dc.PodExecutor("default", "nginx-5c6d8", "nginx", []string{"nginx", "-T"}, context.Background())
+			if test.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && string(output) != "nginx -T" {
+				t.Errorf("unexpected output %q", output)
+			}
+			if len(dc.attempts) != test.attempts {
+				t.Errorf("%d failed attempts recorded, expected %d", len(dc.attempts), test.attempts)
+			}
+			for _, attempt := range dc.attempts {
+				if attempt.Operation != "exec default/nginx-5c6d8/nginx" {
+					t.Errorf("unexpected operation %q", attempt.Operation)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryCRD(t *testing.T) {
+	virtualServers := schema.GroupVersionResource{Group: "k8s.nginx.org", Version: "v1", Resource: "virtualservers"}
+	gatewayClasses := schema.GroupVersionResource{Group: crds.GatewayAPIGroup, Version: "v1", Resource: "gatewayclasses"}
+	dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		virtualServers: "VirtualServerList",
+		gatewayClasses: "GatewayClassList",
+	})
+	objects := []struct {
+		gvr       schema.GroupVersionResource
+		kind      string
+		namespace string
+		name      string
+	}{
+		{virtualServers, "VirtualServer", "default", "cafe"},
+		{virtualServers, "VirtualServer", "other", "tea"},
+		{gatewayClasses, "GatewayClass", "", "nginx"},
+	}
+	for _, object := range objects {
+		item := &unstructured.Unstructured{}
+		item.SetGroupVersionKind(object.gvr.GroupVersion().WithKind(object.kind))
+		item.SetNamespace(object.namespace)
+		item.SetName(object.name)
+		if err := dynamicClient.Tracker().Create(object.gvr, item, object.namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dc := &DataCollector{K8sDynamicClient: dynamicClient}
+
+	tests := []struct {
+		name      string
+		crd       crds.Crd
+		namespace string
+		want      []string
+	}{
+		{"namespaced", crds.Crd{Resource: "virtualservers", Group: "k8s.nginx.org", Version: "v1"}, "default", []string{"cafe"}},
+		{"all namespaces", crds.Crd{Resource: "virtualservers", Group: "k8s.nginx.org", Version: "v1"}, "", []string{"cafe", "tea"}},
+		{"cluster-scoped", crds.Crd{Resource: "gatewayclasses", Group: crds.GatewayAPIGroup, Version: "v1", ClusterScoped: true}, "default", []string{"nginx"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := dc.QueryCRD(test.crd, test.namespace, context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			list := &unstructured.UnstructuredList{}
+			if err = list.UnmarshalJSON(result); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, item := range list.Items {
+				names = append(names, item.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, expected %v", names, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveCRDsListsOnce(t *testing.T) {
+	crdClientSet := crdFake.NewClientset(&apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "virtualservers.k8s.nginx.org"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:    "k8s.nginx.org",
+			Names:    apiextensionsv1.CustomResourceDefinitionNames{Plural: "virtualservers"},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{Name: "v1", Served: true, Storage: true}},
+		},
+	})
+	lists, failures := 0, 1
+	crdClientSet.PrependReactor("list", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		if failures > 0 {
+			failures--
+			return true, nil, errors.New("connection refused")
+		}
+		return false, nil, nil
+	})
+	dc := &DataCollector{K8sCrdClientSet: crdClientSet}
+	crdList := []crds.Crd{{Resource: "virtualservers", Group: "k8s.nginx.org", Version: "v1"}}
+
+	if _, _, err := dc.ResolveCRDs(crdList, false, context.Background()); err == nil {
+		t.Fatal("expected the error of the first list")
+	}
+	for range 3 {
+		resolved, notInstalled, err := dc.ResolveCRDs(crdList, false, context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resolved) != 1 || len(notInstalled) != 0 {
+			t.Errorf("unexpected resolved %v and not installed %v", resolved, notInstalled)
+		}
+	}
+	if lists != 2 {
+		t.Errorf("%d lists of the CRDs, expected one failed and one cached", lists)
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := encrypt.ParseRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		output   OutputOptions
+		wantPath string
+		wantRoot string
+		wantErr  bool
+	}{
+		{name: "template", output: OutputOptions{Dir: "out", NameTemplate: "{{.Product}}-supportpkg", Format: archive.Zip}, wantPath: filepath.Join("out", "nic-supportpkg.zip"), wantRoot: "nic-supportpkg"},
+		{name: "path", output: OutputOptions{Path: "out/bundle.tar.zst", Format: archive.TarZst}, wantPath: "out/bundle.tar.zst", wantRoot: "bundle"},
+		{name: "path without extension", output: OutputOptions{Path: "bundle", Format: archive.Zip}, wantPath: "bundle", wantRoot: "bundle"},
+		{name: "encrypted path", output: OutputOptions{Path: "bundle.zip.age", Format: archive.Zip, Recipient: recipient}, wantPath: "bundle.zip.age", wantRoot: "bundle"},
+		{name: "conflicting extension", output: OutputOptions{Path: "bundle.tar.gz", Format: archive.Zip}, wantErr: true},
+		{name: "conflicting encrypted extension", output: OutputOptions{Path: "bundle.zip.age", Format: archive.TarGz, Recipient: recipient}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dc := &DataCollector{Output: test.output}
+			path, rootDirName, err := dc.OutputPath("nic")
+			if test.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if path != test.wantPath || rootDirName != test.wantRoot {
+				t.Errorf("got %q and %q, expected %q and %q", path, rootDirName, test.wantPath, test.wantRoot)
+			}
+		})
+	}
+}
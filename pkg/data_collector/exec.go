@@ -0,0 +1,81 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package data_collector
+
+import (
+	"bytes"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// CommandExecutor runs a command in a container of a pod and returns its output.
+type CommandExecutor interface {
+	Exec(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error)
+}
+
+// ExecutorFunc lets a function be used as a CommandExecutor, such as a stub in tests.
+type ExecutorFunc func(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error)
+
+func (f ExecutorFunc) Exec(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error) {
+	return f(namespace, pod, container, command, ctx)
+}
+
+// spdyExecutor runs commands through the exec subresource of the pods.
+type spdyExecutor struct {
+	coreClientSet kubernetes.Interface
+	config        *rest.Config
+}
+
+func (e *spdyExecutor) Exec(namespace string, pod string, container string, command []string, ctx context.Context) ([]byte, error) {
+	req := e.coreClientSet.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Command:   command,
+			Container: container,
+			Stdin:     false,
+			Stdout:    true,
+			Stderr:    true,
+			TTY:       true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  nil,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if stdout.Len() > 0 || stderr.Len() > 0 {
+		response := append(stdout.Bytes(), stderr.Bytes()...)
+		return response, nil
+	} else {
+		return nil, err
+	}
+}
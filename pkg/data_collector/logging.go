@@ -0,0 +1,93 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package data_collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+)
+
+// MirrorLogs sends the log records to w as well as to supportpkg.log, in text form, for --verbose.
+func (c *DataCollector) MirrorLogs(w io.Writer) {
+	mirror := slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	c.Logger = slog.New(jobHandler{teeHandler{c.logHandler, mirror}})
+}
+
+// jobHandler adds the name of the job running with the context of each record, so that jobs
+// only have to log with their context.
+type jobHandler struct {
+	slog.Handler
+}
+
+func (h jobHandler) Handle(ctx context.Context, record slog.Record) error {
+	if job := progress.JobFromContext(ctx); job != "" {
+		record.AddAttrs(slog.String("job", job))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h jobHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return jobHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h jobHandler) WithGroup(name string) slog.Handler {
+	return jobHandler{h.Handler.WithGroup(name)}
+}
+
+// teeHandler sends each record to all of its handlers.
+type teeHandler []slog.Handler
+
+func (h teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
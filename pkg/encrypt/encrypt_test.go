@@ -0,0 +1,34 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package encrypt
+
+import (
+	"testing"
+)
+
+// TestDefaultRecipient checks the support key injected by the release build, run there with the
+// same -ldflags as the binaries.
+func TestDefaultRecipient(t *testing.T) {
+	if DefaultRecipient == "" {
+		t.Skip("no default support key in this build")
+	}
+	if _, err := ParseRecipient(DefaultRecipientName); err != nil {
+		t.Fatal(err)
+	}
+}
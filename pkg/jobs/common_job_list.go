@@ -34,14 +34,16 @@ import (
 func CommonJobList() []Job {
 	jobList := []Job{
 		{
-			Name:    "pod-list",
-			Timeout: time.Second * 10,
+			Name:        "pod-list",
+			Description: "List the pods of the namespaces",
+			Permissions: []string{"list pods"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve pod list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "pods.json")] = jsonResult
@@ -51,35 +53,39 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "collect-pods-logs",
-			Timeout: time.Second * 120,
+			Name:        "collect-pods-logs",
+			Description: "Collect the logs of every container of the pods of the namespaces",
+			Permissions: []string{"list pods", "get pods/log"},
+			Timeout:     time.Second * 120,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve pod list", "namespace", namespace, "error", err)
+						continue
 					}
 					for _, pod := range pods.Items {
 						for _, container := range pod.Spec.Containers {
+							dc.ReportPod(ctx, namespace, pod.Name, container.Name)
 							logFileName := filepath.Join(dc.BaseDir, "logs", namespace, fmt.Sprintf("%s__%s.txt", pod.Name, container.Name))
 							bufferedLogs := dc.K8sCoreClientSet.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: container.Name})
-							podLogs, err := bufferedLogs.Stream(context.TODO())
+							podLogs, err := bufferedLogs.Stream(ctx)
 							if err != nil {
-								dc.Logger.Printf("\tCould not get logs for pod %s/%s: %v\n", namespace, pod.Name, err)
+								dc.Logger.ErrorContext(ctx, "Could not get logs", "namespace", namespace, "pod", pod.Name, "container", container.Name, "error", err)
 							} else {
 								buf := new(bytes.Buffer)
 								_, err := io.Copy(buf, podLogs)
 								if err != nil {
 									jobResult.Error = err
-									dc.Logger.Printf("\tCould not copy log buffer for pod %s/%s: %v\n", namespace, pod.Name, err)
+									dc.Logger.ErrorContext(ctx, "Could not copy log buffer", "namespace", namespace, "pod", pod.Name, "container", container.Name, "error", err)
 								} else {
 									jobResult.Files[logFileName] = buf.Bytes()
 								}
 								err = podLogs.Close()
 								if err != nil {
 									jobResult.Error = err
-									dc.Logger.Printf("\tCould not close logs for pod %s/%s: %v\n", namespace, pod.Name, err)
+									dc.Logger.ErrorContext(ctx, "Could not close logs", "namespace", namespace, "pod", pod.Name, "container", container.Name, "error", err)
 								}
 							}
 						}
@@ -89,14 +95,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "events-list",
-			Timeout: time.Second * 10,
+			Name:        "events-list",
+			Description: "List the events of the namespaces",
+			Permissions: []string{"list events"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve events list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve events list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "events.json")] = jsonResult
@@ -106,14 +114,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "configmap-list",
-			Timeout: time.Second * 10,
+			Name:        "configmap-list",
+			Description: "List the config maps of the namespaces",
+			Permissions: []string{"list configmaps"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve configmap list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve configmap list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "configmaps.json")] = jsonResult
@@ -124,14 +134,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "service-list",
-			Timeout: time.Second * 10,
+			Name:        "service-list",
+			Description: "List the services of the namespaces",
+			Permissions: []string{"list services"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve services list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve services list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "services.json")] = jsonResult
@@ -141,14 +153,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "deployment-list",
-			Timeout: time.Second * 10,
+			Name:        "deployment-list",
+			Description: "List the deployments of the namespaces",
+			Permissions: []string{"list deployments.apps"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve deployments list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve deployments list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "deployments.json")] = jsonResult
@@ -158,14 +172,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "statefulset-list",
-			Timeout: time.Second * 10,
+			Name:        "statefulset-list",
+			Description: "List the stateful sets of the namespaces",
+			Permissions: []string{"list statefulsets.apps"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve statefulsets list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve statefulsets list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "statefulsets.json")] = jsonResult
@@ -175,14 +191,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "daemonsets-list",
-			Timeout: time.Second * 10,
+			Name:        "daemonsets-list",
+			Description: "List the daemon sets of the namespaces",
+			Permissions: []string{"list daemonsets.apps"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve daemonsets list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve daemonsets list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "daemonsets.json")] = jsonResult
@@ -192,14 +210,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "replicaset-list",
-			Timeout: time.Second * 10,
+			Name:        "replicaset-list",
+			Description: "List the replica sets of the namespaces",
+			Permissions: []string{"list replicasets.apps"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve replicasets list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve replicasets list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "replicasets.json")] = jsonResult
@@ -209,14 +229,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "lease-list",
-			Timeout: time.Second * 10,
+			Name:        "lease-list",
+			Description: "List the leases of the namespaces",
+			Permissions: []string{"list leases.coordination.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoordinationV1().Leases(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve leases list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve leases list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "leases.json")] = jsonResult
@@ -226,14 +248,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "roles-list",
-			Timeout: time.Second * 10,
+			Name:        "roles-list",
+			Description: "List the roles of the namespaces",
+			Permissions: []string{"list roles.rbac.authorization.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.RbacV1().Roles(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve roles list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve roles list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "rbac", namespace, "roles.json")] = jsonResult
@@ -243,14 +267,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "serviceaccounts-list",
-			Timeout: time.Second * 10,
+			Name:        "serviceaccounts-list",
+			Description: "List the service accounts of the namespaces",
+			Permissions: []string{"list serviceaccounts"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve serviceaccounts list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve serviceaccounts list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "rbac", namespace, "serviceaccounts.json")] = jsonResult
@@ -260,14 +286,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "rolebindings-list",
-			Timeout: time.Second * 10,
+			Name:        "rolebindings-list",
+			Description: "List the role bindings of the namespaces",
+			Permissions: []string{"list rolebindings.rbac.authorization.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					result, err := dc.K8sCoreClientSet.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve role bindings list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve role bindings list", "namespace", namespace, "error", err)
 					} else {
 						jsonResult, _ := json.MarshalIndent(result, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "rbac", namespace, "rolebindings.json")] = jsonResult
@@ -277,13 +305,15 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "k8s-version",
-			Timeout: time.Second * 10,
+			Name:        "k8s-version",
+			Description: "Get the version of the Kubernetes API server",
+			Permissions: nil,
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
-				result, err := dc.K8sCoreClientSet.ServerVersion()
+				result, err := dc.K8sCoreClientSet.Discovery().ServerVersion()
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve server version: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve server version", "error", err)
 				} else {
 					jsonResult, _ := json.MarshalIndent(result, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "version.json")] = jsonResult
@@ -292,13 +322,15 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "crd-info",
-			Timeout: time.Second * 10,
+			Name:        "crd-info",
+			Description: "List the custom resource definitions of the cluster",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
-				result, err := dc.K8sCrdClientSet.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
+				result, err := dc.CRDDefinitions(ctx)
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve crd data: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve crd data", "error", err)
 				} else {
 					jsonResult, _ := json.MarshalIndent(result, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "crd.json")] = jsonResult
@@ -307,13 +339,15 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "clusterroles-info",
-			Timeout: time.Second * 10,
+			Name:        "clusterroles-info",
+			Description: "List the cluster roles",
+			Permissions: []string{"list clusterroles.rbac.authorization.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				result, err := dc.K8sCoreClientSet.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve clusterroles data: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve clusterroles data", "error", err)
 				} else {
 					jsonResult, _ := json.MarshalIndent(result, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "rbac", "clusterroles.json")] = jsonResult
@@ -322,13 +356,15 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "clusterroles-bindings-info",
-			Timeout: time.Second * 10,
+			Name:        "clusterroles-bindings-info",
+			Description: "List the cluster role bindings",
+			Permissions: []string{"list clusterrolebindings.rbac.authorization.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				result, err := dc.K8sCoreClientSet.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve clusterroles binding data: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve clusterroles binding data", "error", err)
 				} else {
 					jsonResult, _ := json.MarshalIndent(result, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "rbac", "clusterrolesbindings.json")] = jsonResult
@@ -337,13 +373,15 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "nodes-info",
-			Timeout: time.Second * 10,
+			Name:        "nodes-info",
+			Description: "List the nodes of the cluster",
+			Permissions: []string{"list nodes"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				result, err := dc.K8sCoreClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve nodes information: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve nodes information", "error", err)
 				} else {
 					jsonResult, _ := json.MarshalIndent(result, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "nodes.json")] = jsonResult
@@ -352,21 +390,23 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "metrics-info",
-			Timeout: time.Second * 10,
+			Name:        "metrics-info",
+			Description: "Collect the resource usage of the nodes and of the pods of the namespaces",
+			Permissions: []string{"list nodes.metrics.k8s.io", "list pods.metrics.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				nodeMetrics, err := dc.K8sMetricsClientSet.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve nodes metrics: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve nodes metrics", "error", err)
 				} else {
 					jsonNodeMetrics, _ := json.MarshalIndent(nodeMetrics, "", "  ")
 					jobResult.Files[filepath.Join(dc.BaseDir, "metrics", "node-resource-list.json")] = jsonNodeMetrics
 				}
 				for _, namespace := range dc.Namespaces {
-					podMetrics, _ := dc.K8sMetricsClientSet.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+					podMetrics, err := dc.K8sMetricsClientSet.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pods metrics for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve pods metrics", "namespace", namespace, "error", err)
 					} else {
 						jsonPodMetrics, _ := json.MarshalIndent(podMetrics, "", "  ")
 						jobResult.Files[filepath.Join(dc.BaseDir, "metrics", namespace, "pod-resource-list.json")] = jsonPodMetrics
@@ -376,14 +416,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "helm-info",
-			Timeout: time.Second * 10,
+			Name:        "helm-info",
+			Description: "Record the Helm settings",
+			Permissions: nil,
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				settings := dc.K8sHelmClientSet[dc.Namespaces[0]].GetSettings()
 				jsonSettings, err := json.MarshalIndent(settings, "", "  ")
 				if err != nil {
-					dc.Logger.Printf("\tCould not retrieve helm information: %v\n", err)
+					dc.Logger.ErrorContext(ctx, "Could not retrieve helm information", "error", err)
 				} else {
 					jobResult.Files[filepath.Join(dc.BaseDir, "helm", "settings.json")] = jsonSettings
 				}
@@ -391,14 +433,16 @@ func CommonJobList() []Job {
 			},
 		},
 		{
-			Name:    "helm-deployments",
-			Timeout: time.Second * 10,
+			Name:        "helm-deployments",
+			Description: "List the Helm releases deployed in the namespaces",
+			Permissions: []string{"list secrets"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
 					releases, err := dc.K8sHelmClientSet[namespace].ListDeployedReleases()
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve helm deployments for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve helm deployments", "namespace", namespace, "error", err)
 					} else {
 						for _, release := range releases {
 							jsonRelease, _ := json.MarshalIndent(release, "", "  ")
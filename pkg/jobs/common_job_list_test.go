@@ -0,0 +1,199 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func commonCluster() testcluster.Cluster {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "default", Name: name}
+	}
+	return testcluster.Cluster{
+		Objects: []runtime.Object{
+			testPod("default", "nginx-ingress-7d9c5", "nginx-ingress"),
+			&corev1.Event{ObjectMeta: meta("nginx-ingress-7d9c5.1"), Reason: "Started"},
+			&corev1.ConfigMap{ObjectMeta: meta("nginx-config"), Data: map[string]string{"worker-processes": "2"}},
+			&corev1.Service{ObjectMeta: meta("nginx-ingress")},
+			&corev1.ServiceAccount{ObjectMeta: meta("nginx-ingress-sa")},
+			&appsv1.Deployment{ObjectMeta: meta("nginx-ingress")},
+			&appsv1.StatefulSet{ObjectMeta: meta("nginx-ingress-cache")},
+			&appsv1.DaemonSet{ObjectMeta: meta("nginx-ingress-ds")},
+			&appsv1.ReplicaSet{ObjectMeta: meta("nginx-ingress-7d9c5")},
+			&coordinationv1.Lease{ObjectMeta: meta("nginx-ingress-leader")},
+			&rbacv1.Role{ObjectMeta: meta("nginx-ingress-role")},
+			&rbacv1.RoleBinding{ObjectMeta: meta("nginx-ingress-rolebinding")},
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "nginx-ingress-clusterrole"}},
+			&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "nginx-ingress-clusterrolebinding"}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		},
+		Definitions: []runtime.Object{
+			testCRD("k8s.nginx.org", "virtualservers", "VirtualServer", apiextensionsv1.NamespaceScoped, "v1"),
+		},
+		NodeMetrics: []*metricsv1beta1.NodeMetrics{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}},
+		PodMetrics:  []*metricsv1beta1.PodMetrics{{ObjectMeta: meta("nginx-ingress-7d9c5")}},
+		Releases: map[string][]*release.Release{
+			"default": {{Name: "nginx-ingress", Namespace: "default", Manifest: "kind: Deployment"}},
+		},
+	}
+}
+
+func TestCommonJobList(t *testing.T) {
+	tests := []jobTest{
+		{
+			job:   "pod-list",
+			files: map[string]string{"resources/default/pods.json": "nginx-ingress-7d9c5"},
+		},
+		{
+			job:   "pod-list",
+			name:  "forbidden",
+			setup: fail("list pods"),
+		},
+		{
+			job:   "collect-pods-logs",
+			files: map[string]string{"logs/default/nginx-ingress-7d9c5__nginx-ingress.txt": "fake logs"},
+		},
+		{
+			job:   "collect-pods-logs",
+			name:  "forbidden",
+			setup: fail("list pods"),
+		},
+		{
+			job:   "events-list",
+			files: map[string]string{"resources/default/events.json": "Started"},
+		},
+		{
+			job:   "events-list",
+			name:  "forbidden",
+			setup: fail("list events"),
+		},
+		{
+			job:   "configmap-list",
+			files: map[string]string{"resources/default/configmaps.json": "worker-processes"},
+		},
+		{
+			job:   "service-list",
+			files: map[string]string{"resources/default/services.json": "nginx-ingress"},
+		},
+		{
+			job:   "deployment-list",
+			files: map[string]string{"resources/default/deployments.json": "nginx-ingress"},
+		},
+		{
+			job:   "statefulset-list",
+			files: map[string]string{"resources/default/statefulsets.json": "nginx-ingress-cache"},
+		},
+		{
+			job:   "daemonsets-list",
+			files: map[string]string{"resources/default/daemonsets.json": "nginx-ingress-ds"},
+		},
+		{
+			job:   "replicaset-list",
+			files: map[string]string{"resources/default/replicasets.json": "nginx-ingress-7d9c5"},
+		},
+		{
+			job:   "lease-list",
+			files: map[string]string{"resources/default/leases.json": "nginx-ingress-leader"},
+		},
+		{
+			job:   "roles-list",
+			files: map[string]string{"k8s/rbac/default/roles.json": "nginx-ingress-role"},
+		},
+		{
+			job:   "serviceaccounts-list",
+			files: map[string]string{"k8s/rbac/default/serviceaccounts.json": "nginx-ingress-sa"},
+		},
+		{
+			job:   "rolebindings-list",
+			files: map[string]string{"k8s/rbac/default/rolebindings.json": "nginx-ingress-rolebinding"},
+		},
+		{
+			job:   "k8s-version",
+			files: map[string]string{"k8s/version.json": "gitVersion"},
+		},
+		{
+			job:   "k8s-version",
+			name:  "unreachable",
+			setup: fail("get version"),
+		},
+		{
+			job:   "crd-info",
+			files: map[string]string{"k8s/crd.json": "virtualservers.k8s.nginx.org"},
+		},
+		{
+			job:   "crd-info",
+			name:  "forbidden",
+			setup: fail("list customresourcedefinitions"),
+		},
+		{
+			job:   "clusterroles-info",
+			files: map[string]string{"k8s/rbac/clusterroles.json": "nginx-ingress-clusterrole"},
+		},
+		{
+			job:   "clusterroles-bindings-info",
+			files: map[string]string{"k8s/rbac/clusterrolesbindings.json": "nginx-ingress-clusterrolebinding"},
+		},
+		{
+			job:   "nodes-info",
+			files: map[string]string{"k8s/nodes.json": "node-1"},
+		},
+		{
+			job: "metrics-info",
+			files: map[string]string{
+				"metrics/node-resource-list.json":        "node-1",
+				"metrics/default/pod-resource-list.json": "nginx-ingress-7d9c5",
+			},
+		},
+		{
+			job:   "metrics-info",
+			name:  "no metrics server",
+			setup: fail("list pods"),
+			files: map[string]string{"metrics/node-resource-list.json": "node-1"},
+		},
+		{
+			job:   "helm-info",
+			files: map[string]string{"helm/settings.json": "/kube/config"},
+		},
+		{
+			job: "helm-deployments",
+			files: map[string]string{
+				"helm/default/nginx-ingress_release.json": `"name": "nginx-ingress"`,
+				"helm/default/nginx-ingress_manifest.txt": "kind: Deployment",
+			},
+		},
+		{
+			job:   "helm-deployments",
+			name:  "forbidden",
+			setup: fail("list releases"),
+		},
+	}
+	runJobTests(t, "", CommonJobList(), []string{"default"}, commonCluster, tests)
+}
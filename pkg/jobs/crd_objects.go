@@ -0,0 +1,140 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CRDObjectsJob collects the objects of the custom resources, for products registered without a
+// crd-objects job of their own.
+func CRDObjectsJob(crdList func() []crds.Crd) Job {
+	permissions := []string{"list customresourcedefinitions.apiextensions.k8s.io"}
+	for _, crd := range crdList() {
+		permission := "list *." + crd.Group
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return Job{
+		Name:        "crd-objects",
+		Description: "Collect the custom resources of the product",
+		Permissions: permissions,
+		Timeout:     time.Second * 10,
+		Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+			jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+			collectCRDObjects(dc, ctx, crdList(), dc.AllCRDVersions, &jobResult)
+			ch <- jobResult
+		},
+	}
+}
+
+// collectCRDObjects resolves the CRDs against the cluster and stores their objects in jobResult.
+// Cluster-scoped objects are collected once, namespaced ones for each namespace or, with
+// dc.AllNamespaces, for every namespace holding any. When allVersions is set every served
+// version is collected and the version is added to the file name.
+func collectCRDObjects(dc *data_collector.DataCollector, ctx context.Context, crdList []crds.Crd, allVersions bool, jobResult *JobResult) {
+	resolved, notInstalled, err := dc.ResolveCRDs(crdList, allVersions, ctx)
+	if err != nil {
+		dc.Logger.ErrorContext(ctx, "Could not retrieve crd data", "error", err)
+		jobResult.Error = err
+		return
+	}
+	for _, crd := range notInstalled {
+		dc.Logger.WarnContext(ctx, "CRD is not installed", "crd", crd.Resource+"."+crd.Group)
+	}
+
+	for _, crd := range resolved {
+		fileName := crd.Resource + ".json"
+		if allVersions {
+			fileName = crd.Resource + "_" + crd.Version + ".json"
+		}
+		if crd.ClusterScoped {
+			result, err := dc.QueryCRD(crd, metav1.NamespaceNone, ctx)
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be collected", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "error", err)
+			} else {
+				jobResult.Files[filepath.Join(dc.BaseDir, "crds", "cluster-scoped", fileName)] = indentJSON(result)
+			}
+			continue
+		}
+		if dc.AllNamespaces {
+			result, err := dc.QueryCRD(crd, metav1.NamespaceAll, ctx)
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be collected in all namespaces", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "error", err)
+				continue
+			}
+			byNamespace, err := splitByNamespace(result)
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be parsed", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "error", err)
+				continue
+			}
+			for namespace, namespaceResult := range byNamespace {
+				jobResult.Files[filepath.Join(dc.BaseDir, "crds", namespace, fileName)] = namespaceResult
+			}
+			continue
+		}
+		for _, namespace := range dc.Namespaces {
+			result, err := dc.QueryCRD(crd, namespace, ctx)
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be collected", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "namespace", namespace, "error", err)
+			} else {
+				jobResult.Files[filepath.Join(dc.BaseDir, "crds", namespace, fileName)] = indentJSON(result)
+			}
+		}
+	}
+}
+
+// splitByNamespace splits a list of custom resources into one indented list per namespace.
+func splitByNamespace(raw []byte) (map[string][]byte, error) {
+	list := &unstructured.UnstructuredList{}
+	if err := list.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	itemsByNamespace := make(map[string][]unstructured.Unstructured)
+	for _, item := range list.Items {
+		itemsByNamespace[item.GetNamespace()] = append(itemsByNamespace[item.GetNamespace()], item)
+	}
+	result := make(map[string][]byte)
+	for namespace, items := range itemsByNamespace {
+		namespaceList := &unstructured.UnstructuredList{Object: list.Object, Items: items}
+		jsonResult, err := json.MarshalIndent(namespaceList, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		result[namespace] = jsonResult
+	}
+	return result, nil
+}
+
+func indentJSON(raw []byte) []byte {
+	var jsonResult bytes.Buffer
+	_ = json.Indent(&jsonResult, raw, "", "  ")
+	return jsonResult.Bytes()
+}
@@ -0,0 +1,203 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	stateValid   = "Valid"
+	stateWarning = "Warning"
+	stateInvalid = "Invalid"
+)
+
+// negativePolarityConditions are the Gateway API conditions that signal a problem when True.
+var negativePolarityConditions = []string{"Conflicted", "PartiallyInvalid"}
+
+// crdStatus is one row of the crd-status-summary report.
+type crdStatus struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	State     string `json:"state"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (s crdStatus) needsAttention() bool {
+	return s.State == stateWarning || s.State == stateInvalid
+}
+
+// summarizeCRDStatus collects the status of the objects of the given CRDs into the
+// crd-status-summary text and JSON reports.
+func summarizeCRDStatus(dc *data_collector.DataCollector, ctx context.Context, crdList []crds.Crd, jobResult *JobResult) {
+	resolved, notInstalled, err := dc.ResolveCRDs(crdList, false, ctx)
+	if err != nil {
+		dc.Logger.ErrorContext(ctx, "Could not retrieve crd data", "error", err)
+		jobResult.Error = err
+		return
+	}
+	for _, crd := range notInstalled {
+		dc.Logger.WarnContext(ctx, "CRD is not installed", "crd", crd.Resource+"."+crd.Group)
+	}
+
+	statuses := []crdStatus{}
+	for _, crd := range resolved {
+		var namespaces []string
+		switch {
+		case crd.ClusterScoped:
+			namespaces = []string{metav1.NamespaceNone}
+		case dc.AllNamespaces:
+			namespaces = []string{metav1.NamespaceAll}
+		default:
+			namespaces = dc.Namespaces
+		}
+		for _, namespace := range namespaces {
+			result, err := dc.QueryCRD(crd, namespace, ctx)
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be collected", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "namespace", namespace, "error", err)
+				continue
+			}
+			list := &unstructured.UnstructuredList{}
+			if err = list.UnmarshalJSON(result); err != nil {
+				dc.Logger.ErrorContext(ctx, "CRD could not be parsed", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "error", err)
+				continue
+			}
+			for _, item := range list.Items {
+				statuses = append(statuses, objectStatus(item))
+			}
+		}
+	}
+
+	slices.SortStableFunc(statuses, func(a, b crdStatus) int {
+		return strings.Compare(a.Kind+"/"+a.Namespace+"/"+a.Name, b.Kind+"/"+b.Namespace+"/"+b.Name)
+	})
+	jsonResult, _ := json.MarshalIndent(statuses, "", "  ")
+	jobResult.Files[filepath.Join(dc.BaseDir, "crds", "crd-status-summary.json")] = jsonResult
+	jobResult.Files[filepath.Join(dc.BaseDir, "crds", "crd-status-summary.txt")] = formatCRDStatus(statuses)
+}
+
+// objectStatus extracts the state of an object, either from the status.state, status.reason and
+// status.message fields used by NIC resources, or from the conditions used by Gateway API resources.
+func objectStatus(item unstructured.Unstructured) crdStatus {
+	status := crdStatus{
+		Kind:      item.GetKind(),
+		Name:      item.GetName(),
+		Namespace: item.GetNamespace(),
+	}
+	if state, found, _ := unstructured.NestedString(item.Object, "status", "state"); found {
+		status.State = state
+		status.Reason, _, _ = unstructured.NestedString(item.Object, "status", "reason")
+		status.Message, _, _ = unstructured.NestedString(item.Object, "status", "message")
+		return status
+	}
+
+	conditions := nestedConditions(item.Object, "status", "conditions")
+	for _, field := range []string{"parents", "ancestors"} {
+		entries, _, _ := unstructured.NestedSlice(item.Object, "status", field)
+		for _, entry := range entries {
+			if entryMap, ok := entry.(map[string]interface{}); ok {
+				conditions = append(conditions, nestedConditions(entryMap, "conditions")...)
+			}
+		}
+	}
+	if len(conditions) == 0 {
+		return status
+	}
+
+	// The first unhealthy condition explains the state of the object
+	status.State = stateValid
+	for _, condition := range conditions {
+		healthy := condition.Status == metav1.ConditionTrue
+		if slices.Contains(negativePolarityConditions, condition.Type) {
+			healthy = condition.Status == metav1.ConditionFalse
+		}
+		if !healthy {
+			status.State = stateInvalid
+			if condition.Type == "ResolvedRefs" || condition.Type == "PartiallyInvalid" {
+				status.State = stateWarning
+			}
+			status.Reason = condition.Reason
+			status.Message = condition.Message
+			return status
+		}
+		if status.Reason == "" {
+			status.Reason = condition.Reason
+			status.Message = condition.Message
+		}
+	}
+	return status
+}
+
+func nestedConditions(object map[string]interface{}, fields ...string) []metav1.Condition {
+	entries, _, _ := unstructured.NestedSlice(object, fields...)
+	var conditions []metav1.Condition
+	for _, entry := range entries {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var condition metav1.Condition
+		condition.Type, _, _ = unstructured.NestedString(entryMap, "type")
+		status, _, _ := unstructured.NestedString(entryMap, "status")
+		condition.Status = metav1.ConditionStatus(status)
+		condition.Reason, _, _ = unstructured.NestedString(entryMap, "reason")
+		condition.Message, _, _ = unstructured.NestedString(entryMap, "message")
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// formatCRDStatus renders the statuses as a table, flagging the objects in Warning or Invalid state with "!".
+func formatCRDStatus(statuses []crdStatus) []byte {
+	attention := 0
+	for _, status := range statuses {
+		if status.needsAttention() {
+			attention++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d of %d objects in Warning or Invalid state\n\n", attention, len(statuses))
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tKIND\tNAMESPACE\tNAME\tSTATE\tREASON\tMESSAGE")
+	for _, status := range statuses {
+		marker := ""
+		if status.needsAttention() {
+			marker = "!"
+		}
+		message := strings.Join(strings.Fields(status.Message), " ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", marker, status.Kind, status.Namespace, status.Name, status.State, status.Reason, message)
+	}
+	_ = w.Flush()
+	return buf.Bytes()
+}
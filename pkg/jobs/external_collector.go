@@ -0,0 +1,221 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+)
+
+const (
+	// ExternalCollectorPrefix starts the name of the external collector executables found on PATH
+	ExternalCollectorPrefix = "nginx-supportpkg-collector-"
+	// ExternalCollectorTimeout is the timeout of the external collectors, changed like that of any job
+	ExternalCollectorTimeout = time.Minute
+	// externalCollectorOutput is how much of the output of a collector is kept for the manifest
+	externalCollectorOutput = 4096
+	// externalCollectorEnvPrefix starts the names of the variables of the environment passed on to the collectors
+	externalCollectorEnvPrefix = "NGINX_SUPPORTPKG_"
+)
+
+// externalCollectorEnv are the other variables of the environment passed on to the collectors,
+// which do not get the rest of it, such as cloud credentials.
+var externalCollectorEnv = []string{"PATH", "HOME", "KUBECONFIG"}
+
+// ExternalCollectorContext is the JSON document an external collector reads on stdin. The
+// namespaces, the kubeconfig and the output directory are also in the NGINX_SUPPORTPKG_NAMESPACES,
+// KUBECONFIG and NGINX_SUPPORTPKG_OUTPUT_DIR environment variables, for shell scripts. Of the
+// environment of the command, the collectors only get PATH, HOME, KUBECONFIG and the
+// NGINX_SUPPORTPKG_* variables.
+type ExternalCollectorContext struct {
+	Namespaces []string `json:"namespaces"`
+	// KubeConfig is empty when the collection does not use a kubeconfig file
+	KubeConfig string `json:"kubeConfig"`
+	// OutputDir is the directory where the collector writes its files, added to the package under collectors/<name>
+	OutputDir   string    `json:"outputDir"`
+	ProductPods []string  `json:"productPods"`
+	Deadline    time.Time `json:"deadline,omitzero"`
+}
+
+// FindExternalCollectors returns the executables of the directories of PATH whose name starts with
+// ExternalCollectorPrefix. When several directories hold the same name, the first one is used.
+func FindExternalCollectors() []string {
+	var found []string
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, ExternalCollectorPrefix) || slices.Contains(names, name) {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			names = append(names, name)
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+	slices.SortFunc(found, func(a, b string) int { return strings.Compare(filepath.Base(a), filepath.Base(b)) })
+	return found
+}
+
+// ExternalCollectorName returns the name of the files of an external collector in the package,
+// its file name without ExternalCollectorPrefix and extension.
+func ExternalCollectorName(executable string) string {
+	name := filepath.Base(executable)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(name, ExternalCollectorPrefix)
+}
+
+// ExternalCollectorJob runs an external collector executable, named collector-<name> after
+// ExternalCollectorName. The collector gets an ExternalCollectorContext on stdin and writes its
+// files in the output directory of the context; it fails when it exits with an error. Every run
+// is recorded in the manifest, with the end of its output, before the job returns even when it
+// times out.
+func ExternalCollectorJob(executable string) Job {
+	name := ExternalCollectorName(executable)
+	return Job{
+		Name:        "collector-" + name,
+		Description: "Run the external collector " + executable,
+		Timeout:     ExternalCollectorTimeout,
+		Wait:        true,
+		Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+			jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+			run := manifest.CollectorRun{Name: name, Executable: executable, ExitCode: -1, Files: []string{}}
+			start := time.Now()
+			defer func() {
+				run.DurationSeconds = time.Since(start).Seconds()
+				if jobResult.Error != nil {
+					run.Error = jobResult.Error.Error()
+					run.Files = []string{}
+				}
+				dc.RecordCollectorRun(run)
+				ch <- jobResult
+			}()
+
+			outputDir, err := os.MkdirTemp("", ExternalCollectorPrefix+name)
+			if err != nil {
+				jobResult.Error = fmt.Errorf("unable to create the output directory: %w", err)
+				return
+			}
+			defer os.RemoveAll(outputDir)
+
+			collectorContext := ExternalCollectorContext{
+				Namespaces:  dc.Namespaces,
+				KubeConfig:  dc.KubeConfig,
+				OutputDir:   outputDir,
+				ProductPods: dc.ProductPods,
+			}
+			if deadline, ok := ctx.Deadline(); ok {
+				collectorContext.Deadline = deadline.UTC()
+			}
+			input, _ := json.Marshal(collectorContext)
+
+			output := &tailWriter{max: externalCollectorOutput}
+			cmd := exec.CommandContext(ctx, executable)
+			cmd.Stdin = bytes.NewReader(input)
+			cmd.Stdout = output
+			cmd.Stderr = output
+			// Children of the collector keeping its output open do not hold the job past its timeout
+			cmd.WaitDelay = time.Second * 5
+			cmd.Env = append(externalCollectorEnviron(),
+				externalCollectorEnvPrefix+"NAMESPACES="+strings.Join(dc.Namespaces, ","),
+				externalCollectorEnvPrefix+"OUTPUT_DIR="+outputDir,
+			)
+			if dc.KubeConfig != "" {
+				cmd.Env = append(cmd.Env, "KUBECONFIG="+dc.KubeConfig)
+			}
+
+			err = cmd.Run()
+			run.Output = string(output.buf)
+			if cmd.ProcessState != nil {
+				run.ExitCode = cmd.ProcessState.ExitCode()
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					err = fmt.Errorf("%w: %w", err, ctx.Err())
+				}
+				dc.Logger.ErrorContext(ctx, "External collector failed", "executable", executable, "exitCode", run.ExitCode, "output", run.Output, "error", err)
+				jobResult.Error = err
+				return
+			}
+
+			// Symbolic links are not followed, so that only the files of the output directory are collected
+			err = filepath.WalkDir(outputDir, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil || !entry.Type().IsRegular() {
+					return err
+				}
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				relativePath, _ := filepath.Rel(outputDir, path)
+				jobResult.Files[filepath.Join(dc.BaseDir, "collectors", name, relativePath)] = content
+				run.Files = append(run.Files, filepath.ToSlash(filepath.Join("collectors", name, relativePath)))
+				return nil
+			})
+			if err != nil {
+				dc.Logger.ErrorContext(ctx, "External collector files could not be read", "executable", executable, "error", err)
+				jobResult.Error = fmt.Errorf("unable to read the files of the collector: %w", err)
+			}
+		},
+	}
+}
+
+// externalCollectorEnviron returns the variables of the environment passed on to the collectors.
+func externalCollectorEnviron() []string {
+	var env []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if slices.Contains(externalCollectorEnv, name) || strings.HasPrefix(name, externalCollectorEnvPrefix) {
+			env = append(env, variable)
+		}
+	}
+	return env
+}
+
+// tailWriter keeps the last max bytes written to it.
+type tailWriter struct {
+	max int
+	buf []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,140 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+)
+
+// writeScript writes an executable shell script in dir.
+func writeScript(t *testing.T, dir string, name string, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExternalCollectorJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the collectors of the test are shell scripts")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		files   map[string]string
+		// excluded must not appear in any of the files
+		excluded string
+		wantErr  bool
+	}{
+		{
+			name: "files",
+			script: `mkdir "$NGINX_SUPPORTPKG_OUTPUT_DIR/status"
+echo "$NGINX_SUPPORTPKG_NAMESPACES" > "$NGINX_SUPPORTPKG_OUTPUT_DIR/namespaces.txt"
+cat > "$NGINX_SUPPORTPKG_OUTPUT_DIR/status/context.json"
+echo collected`,
+			files: map[string]string{
+				"collectors/sidecar/namespaces.txt":      "default,web",
+				"collectors/sidecar/status/context.json": `"productPods":["default/nginx-5c6d8"]`,
+			},
+		},
+		{
+			name:   "environment",
+			script: `env > "$NGINX_SUPPORTPKG_OUTPUT_DIR/env.txt"`,
+			files: map[string]string{
+				"collectors/sidecar/env.txt": "NGINX_SUPPORTPKG_TRACE=1",
+			},
+			excluded: "AWS_SECRET_ACCESS_KEY",
+		},
+		{
+			name:    "exit code",
+			script:  "echo 'sidecar not found' >&2\nexit 3",
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			script:  "exec sleep 5",
+			timeout: 100 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executable := writeScript(t, t.TempDir(), ExternalCollectorPrefix+"sidecar.sh", test.script)
+			job := ExternalCollectorJob(executable)
+			if job.Name != "collector-sidecar" {
+				t.Errorf("unexpected job name %s", job.Name)
+			}
+			if test.timeout > 0 {
+				job.Timeout = test.timeout
+			}
+			t.Setenv("NGINX_SUPPORTPKG_TRACE", "1")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI")
+			dc := newTestCollector(t, []string{"default", "web"}, testcluster.Cluster{})
+			dc.ProductPods = []string{"default/nginx-5c6d8"}
+
+			err := job.Collect(dc, context.Background())
+			if test.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			files := testcluster.ReadFiles(t, dc.BaseDir)
+			if len(files) != len(test.files) {
+				t.Errorf("unexpected files %v", files)
+			}
+			for name, want := range test.files {
+				if !strings.Contains(files[name], want) {
+					t.Errorf("file %s does not contain %q:\n%s", name, want, files[name])
+				}
+				if test.excluded != "" && strings.Contains(files[name], test.excluded) {
+					t.Errorf("file %s contains %q", name, test.excluded)
+				}
+			}
+		})
+	}
+}
+
+func TestFindExternalCollectors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the collectors of the test are shell scripts")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	redis := writeScript(t, first, ExternalCollectorPrefix+"redis", "")
+	writeScript(t, second, ExternalCollectorPrefix+"redis", "")
+	sidecar := writeScript(t, second, ExternalCollectorPrefix+"sidecar", "")
+	writeScript(t, second, "kubectl-nginx_supportpkg", "")
+	if err := os.WriteFile(filepath.Join(second, ExternalCollectorPrefix+"notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", strings.Join([]string{first, filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+
+	if found := FindExternalCollectors(); !slices.Equal(found, []string{redis, sidecar}) {
+		t.Errorf("found %v, expected %v", found, []string{redis, sidecar})
+	}
+}
@@ -23,14 +23,25 @@ import (
 	"errors"
 	"fmt"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
-	"os"
-	"path/filepath"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+	"maps"
+	"path"
+	"slices"
 	"time"
 )
 
 type Job struct {
-	Name    string
-	Timeout time.Duration
+	Name string
+	// Description and Permissions are shown by the list-jobs command, Permissions as the
+	// "verb resource" pairs the job needs, such as "create pods/exec"
+	Description string
+	Permissions []string
+	Timeout     time.Duration
+	// PerPod jobs work on each pod of the product in turn, their Timeout applies to each of them
+	PerPod bool
+	// Wait makes Collect wait for Execute to return once the job has timed out, for jobs recording
+	// their runs in the collector, whose Execute must then return soon after ctx is done
+	Wait    bool
 	Execute func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult)
 }
 
@@ -39,40 +50,105 @@ type JobResult struct {
 	Error error
 }
 
-func (j Job) Collect(dc *data_collector.DataCollector) error {
+// Collect runs the job and writes its files, within its timeout and the deadline of parent, if any.
+func (j Job) Collect(dc *data_collector.DataCollector, parent context.Context) error {
 	ch := make(chan JobResult, 1)
 
-	ctx, cancel := context.WithTimeout(context.Background(), j.Timeout)
+	ctx, cancel := context.WithTimeout(progress.WithJob(parent, j.Name), j.EffectiveTimeout(dc))
 	defer cancel()
 
-	dc.Logger.Printf("\tJob %s has started\n", j.Name)
+	dc.Logger.InfoContext(ctx, "Job has started")
+	dc.Emit(progress.Event{Type: progress.JobStarted, Job: j.Name})
+	err := j.collect(dc, ctx, ch)
+	dc.Emit(progress.Event{Type: progress.JobFinished, Job: j.Name, Err: err})
+	return err
+}
+
+// EffectiveTimeout is the timeout of the job, multiplied by the number of pods of the product for PerPod jobs.
+func (j Job) EffectiveTimeout(dc *data_collector.DataCollector) time.Duration {
+	if j.PerPod && len(dc.ProductPods) > 1 {
+		return j.Timeout * time.Duration(len(dc.ProductPods))
+	}
+	return j.Timeout
+}
+
+// ApplyTimeouts multiplies the timeout of every job by scale, then sets the timeouts given by
+// job name in overrides, which must all name one of the jobs.
+func ApplyTimeouts(jobList []Job, scale float64, overrides map[string]time.Duration) ([]Job, error) {
+	if scale <= 0 {
+		return nil, fmt.Errorf("invalid timeout scale %v, must be positive", scale)
+	}
+	for name := range overrides {
+		if !slices.ContainsFunc(jobList, func(job Job) bool { return job.Name == name }) {
+			return nil, fmt.Errorf("unknown job %q in --job-timeout, see list-jobs", name)
+		}
+	}
+
+	scaled := slices.Clone(jobList)
+	for i := range scaled {
+		scaled[i].Timeout = time.Duration(float64(scaled[i].Timeout) * scale)
+		if timeout, ok := overrides[scaled[i].Name]; ok {
+			scaled[i].Timeout = timeout
+		}
+	}
+	return scaled, nil
+}
+
+func (j Job) collect(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) error {
 	go j.Execute(dc, ctx, ch)
 
 	select {
 	case <-ctx.Done():
-		dc.Logger.Printf("\tJob %s has timed out: %s\n---\n", j.Name, ctx.Err())
+		dc.Logger.ErrorContext(ctx, "Job has timed out", "error", ctx.Err())
+		if j.Wait {
+			<-ch
+		}
 		return errors.New(fmt.Sprintf("Context cancelled: %v", ctx.Err()))
 
 	case jobResults := <-ch:
 		if jobResults.Error != nil {
-			dc.Logger.Printf("\tJob %s has failed: %s\n", j.Name, jobResults.Error)
+			dc.Logger.ErrorContext(ctx, "Job has failed", "error", jobResults.Error)
 			return jobResults.Error
 		}
 
-		for fileName, fileValue := range jobResults.Files {
-			err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm)
-			if err != nil {
-				return fmt.Errorf("MkdirAll failed: %v", err)
-			}
-			file, _ := os.Create(fileName)
-			_, err = file.Write(fileValue)
+		fileNames := slices.Sorted(maps.Keys(jobResults.Files))
+		for _, fileName := range fileNames {
+			fileValue := jobResults.Files[fileName]
+			err := dc.WriteFile(fileName, fileValue)
 			if err != nil {
 				return fmt.Errorf("Write failed: %v", err)
 			}
-			_ = file.Close()
-			dc.Logger.Printf("\tJob %s wrote %d bytes to %s\n", j.Name, len(fileValue), fileName)
+			dc.Emit(progress.Event{Type: progress.JobWrote, Job: j.Name, Detail: fileName, Bytes: len(fileValue)})
+			dc.Logger.DebugContext(ctx, "Job wrote file", "file", fileName, "bytes", len(fileValue))
 		}
-		dc.Logger.Printf("\tJob %s completed successfully\n---\n", j.Name)
+		dc.Logger.InfoContext(ctx, "Job completed successfully")
 		return nil
 	}
 }
+
+// Filter keeps the jobs whose name matches one of the include patterns, or all jobs when there
+// are none, then drops those matching one of the exclude patterns. Patterns use path.Match syntax.
+func Filter(jobList []Job, include []string, exclude []string) ([]Job, error) {
+	for _, pattern := range slices.Concat(include, exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid job pattern %q: %w", pattern, err)
+		}
+	}
+	matches := func(name string, patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		})
+	}
+
+	var filtered []Job
+	for _, job := range jobList {
+		if (len(include) == 0 || matches(job.Name, include)) && !matches(job.Name, exclude) {
+			filtered = append(filtered, job)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.New("no job left to run after applying --include-jobs and --exclude-jobs")
+	}
+	return filtered, nil
+}
@@ -0,0 +1,176 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// jobTest is a case of the table-driven tests of the jobs.
+type jobTest struct {
+	job  string
+	name string
+	// setup changes the cluster of the test, such as to make some requests fail
+	setup func(*testcluster.Cluster)
+	// files maps the files the job writes, relative to the base directory, to a part of their content
+	files map[string]string
+	// excluded must not appear in any of the files, such as the data of a secret
+	excluded string
+	// pods are the pods of the product found before collecting, those detected in the cluster when nil
+	pods    []string
+	wantErr bool
+}
+
+// newTestCollector returns a collector staging its files in a temporary directory, with fake
+// clients serving the objects of the cluster.
+func newTestCollector(t *testing.T, namespaces []string, cluster testcluster.Cluster) *data_collector.DataCollector {
+	t.Helper()
+	dc := &data_collector.DataCollector{
+		BaseDir:    t.TempDir(),
+		Namespaces: namespaces,
+		Logger:     slog.New(slog.DiscardHandler),
+	}
+	testcluster.Install(t, dc, cluster)
+	return dc
+}
+
+// runJobTests runs each test case against a new collector, with the pods of product found in the
+// cluster unless the case sets them, and checks every job of jobList has one.
+func runJobTests(t *testing.T, product string, jobList []Job, namespaces []string, cluster func() testcluster.Cluster, tests []jobTest) {
+	for _, job := range jobList {
+		if !slices.ContainsFunc(tests, func(test jobTest) bool { return test.job == job.Name }) {
+			t.Errorf("job %s has no test", job.Name)
+		}
+	}
+
+	for _, test := range tests {
+		t.Run(strings.TrimSuffix(test.job+"/"+test.name, "/"), func(t *testing.T) {
+			index := slices.IndexFunc(jobList, func(job Job) bool { return job.Name == test.job })
+			if index < 0 {
+				t.Fatalf("job %s not found", test.job)
+			}
+			testCluster := cluster()
+			if test.setup != nil {
+				test.setup(&testCluster)
+			}
+			dc := newTestCollector(t, namespaces, testCluster)
+			dc.ProductPods = test.pods
+			if dc.ProductPods == nil && product != "" {
+				var err error
+				if dc.ProductPods, err = FindProductPods(dc, product, context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := jobList[index].Collect(dc, context.Background())
+			if test.wantErr && err == nil {
+				t.Errorf("expected an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			files := testcluster.ReadFiles(t, dc.BaseDir)
+			for name, content := range files {
+				want, ok := test.files[name]
+				if !ok {
+					t.Errorf("unexpected file %s", name)
+					continue
+				}
+				if !strings.Contains(content, want) {
+					t.Errorf("file %s does not contain %q:\n%s", name, want, content)
+				}
+				if test.excluded != "" && strings.Contains(content, test.excluded) {
+					t.Errorf("file %s contains %q", name, test.excluded)
+				}
+			}
+			for name := range test.files {
+				if _, ok := files[name]; !ok {
+					t.Errorf("missing file %s", name)
+				}
+			}
+		})
+	}
+}
+
+// fail makes a request fail in the cluster of a test.
+func fail(request string) func(*testcluster.Cluster) {
+	return func(cluster *testcluster.Cluster) {
+		cluster.Failures = map[string]error{request: fmt.Errorf("%s is forbidden", request)}
+	}
+}
+
+func testPod(namespace string, name string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+	}
+	return pod
+}
+
+// testCRD defines a custom resource served at the given versions, the first one being the storage version.
+func testCRD(group string, plural string, kind string, scope apiextensionsv1.ResourceScope, versions ...string) *apiextensionsv1.CustomResourceDefinition {
+	definition := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: plural + "." + group},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Plural: plural, Kind: kind},
+			Scope: scope,
+		},
+	}
+	for i, version := range versions {
+		definition.Spec.Versions = append(definition.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    version,
+			Served:  true,
+			Storage: i == 0,
+		})
+	}
+	return definition
+}
+
+// testObject returns a custom resource with the given spec and status.
+func testObject(apiVersion string, kind string, namespace string, name string, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+	}}
+	if namespace != "" {
+		object.SetNamespace(namespace)
+	}
+	if spec != nil {
+		object.Object["spec"] = spec
+	}
+	if status != nil {
+		object.Object["status"] = status
+	}
+	return object
+}
@@ -19,90 +19,98 @@ limitations under the License.
 package jobs
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
 func NGFJobList() []Job {
 	jobList := []Job{
 		{
-			Name:    "exec-nginx-gateway-version",
-			Timeout: time.Second * 10,
+			Name:        "exec-nginx-gateway-version",
+			Description: "Run gateway --help in the NGINX Gateway Fabric pods",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				command := []string{"/usr/bin/gateway", "--help"}
-				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+				for _, pod := range productPods(dc, ctx) {
+					res, err := dc.PodExecutor(pod.Namespace, pod.Name, "nginx-gateway", command, ctx)
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						jobResult.Error = err
+						dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", "nginx-gateway", "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "nginx-gateway") {
-								res, err := dc.PodExecutor(namespace, pod.Name, "nginx-gateway", command, ctx)
-								if err != nil {
-									jobResult.Error = err
-									dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-								} else {
-									jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, pod.Name+"__nginx-gateway-version.txt")] = res
-								}
-							}
-						}
+						jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, pod.Name+"__nginx-gateway-version.txt")] = res
 					}
 				}
 				ch <- jobResult
 			},
 		},
 		{
-			Name:    "exec-nginx-t",
-			Timeout: time.Second * 10,
+			Name:        "exec-nginx-t",
+			Description: "Run nginx -T in the NGINX Gateway Fabric pods to dump the NGINX configuration",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				command := []string{"/usr/sbin/nginx", "-T"}
-				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+				for _, pod := range productPods(dc, ctx) {
+					res, err := dc.PodExecutor(pod.Namespace, pod.Name, "nginx", command, ctx)
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						jobResult.Error = err
+						dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", "nginx", "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "nginx-gateway") {
-								res, err := dc.PodExecutor(namespace, pod.Name, "nginx", command, ctx)
-								if err != nil {
-									jobResult.Error = err
-									dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-								} else {
-									jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, pod.Name+"__nginx-t.txt")] = res
-								}
-							}
-						}
+						jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, pod.Name+"__nginx-t.txt")] = res
 					}
 				}
 				ch <- jobResult
 			},
 		},
 		{
-			Name:    "crd-objects",
-			Timeout: time.Second * 10,
+			Name:        "crd-objects",
+			Description: "Collect the NGINX Gateway Fabric custom resources",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list *.gateway.nginx.org"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
-				for _, namespace := range dc.Namespaces {
-					for _, crd := range crds.GetNGFCRDList() {
-						result, err := dc.QueryCRD(crd, namespace, ctx)
-						if err != nil {
-							dc.Logger.Printf("\tCRD %s.%s/%s could not be collected in namespace %s: %v\n", crd.Resource, crd.Group, crd.Version, namespace, err)
-						} else {
-							var jsonResult bytes.Buffer
-							_ = json.Indent(&jsonResult, result, "", "  ")
-							jobResult.Files[filepath.Join(dc.BaseDir, "crds", namespace, crd.Resource+".json")] = jsonResult.Bytes()
-						}
-					}
+				collectCRDObjects(dc, ctx, crds.GetNGFCRDList(), dc.AllCRDVersions, &jobResult)
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "gateway-api-objects",
+			Description: "Collect the Gateway API resources at every served version",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list *.gateway.networking.k8s.io"},
+			Timeout:     time.Second * 30,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				definitions, err := dc.CRDDefinitions(ctx)
+				if err != nil {
+					dc.Logger.ErrorContext(ctx, "Could not retrieve crd data", "error", err)
+					jobResult.Error = err
+				} else {
+					collectCRDObjects(dc, ctx, crds.GetGatewayAPICRDList(definitions.Items), true, &jobResult)
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "crd-status-summary",
+			Description: "Summarize the status of the gateway classes, gateways and routes",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list *.gateway.networking.k8s.io"},
+			Timeout:     time.Second * 30,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				statusResources := []string{"gatewayclasses", "gateways", "httproutes", "grpcroutes", "tlsroutes", "tcproutes", "udproutes"}
+				var crdList []crds.Crd
+				for _, resource := range statusResources {
+					crdList = append(crdList, crds.Crd{Resource: resource, Group: crds.GatewayAPIGroup})
 				}
+				summarizeCRDStatus(dc, ctx, crdList, &jobResult)
 				ch <- jobResult
 			},
 		},
@@ -0,0 +1,144 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ngfCluster runs NGINX Gateway Fabric in the nginx-gateway namespace, with a gateway that is
+// not programmed and a route with unresolved references.
+func ngfCluster() testcluster.Cluster {
+	condition := func(conditionType string, status string, reason string) map[string]interface{} {
+		return map[string]interface{}{"type": conditionType, "status": status, "reason": reason}
+	}
+	return testcluster.Cluster{
+		Objects: []runtime.Object{
+			testPod("nginx-gateway", "ngf-nginx-gateway-6f8b9", "nginx-gateway", "nginx"),
+		},
+		Definitions: []runtime.Object{
+			testCRD("gateway.nginx.org", "nginxgateways", "NginxGateway", apiextensionsv1.NamespaceScoped, "v1alpha1"),
+			testCRD("gateway.nginx.org", "nginxproxies", "NginxProxy", apiextensionsv1.ClusterScoped, "v1alpha1"),
+			testCRD(crds.GatewayAPIGroup, "gatewayclasses", "GatewayClass", apiextensionsv1.ClusterScoped, "v1", "v1beta1"),
+			testCRD(crds.GatewayAPIGroup, "gateways", "Gateway", apiextensionsv1.NamespaceScoped, "v1", "v1beta1"),
+			testCRD(crds.GatewayAPIGroup, "httproutes", "HTTPRoute", apiextensionsv1.NamespaceScoped, "v1"),
+		},
+		CustomObjects: []*unstructured.Unstructured{
+			testObject("gateway.nginx.org/v1alpha1", "NginxGateway", "nginx-gateway", "ngf-config", map[string]interface{}{}, nil),
+			testObject(crds.GatewayAPIGroup+"/v1", "GatewayClass", "", "nginx",
+				map[string]interface{}{"controllerName": "gateway.nginx.org/nginx-gateway-controller"},
+				map[string]interface{}{"conditions": []interface{}{condition("Accepted", "True", "Accepted")}}),
+			testObject(crds.GatewayAPIGroup+"/v1", "Gateway", "nginx-gateway", "cafe",
+				map[string]interface{}{"gatewayClassName": "nginx"},
+				map[string]interface{}{"conditions": []interface{}{condition("Accepted", "True", "Accepted"), condition("Programmed", "False", "Invalid")}}),
+			testObject(crds.GatewayAPIGroup+"/v1", "HTTPRoute", "nginx-gateway", "coffee",
+				nil,
+				map[string]interface{}{"parents": []interface{}{map[string]interface{}{
+					"conditions": []interface{}{condition("Accepted", "True", "Accepted"), condition("ResolvedRefs", "False", "BackendNotFound")},
+				}}}),
+		},
+	}
+}
+
+func TestNGFJobList(t *testing.T) {
+	execFailure := func(cluster *testcluster.Cluster) {
+		cluster.Exec = func(string, string, string, []string, context.Context) ([]byte, error) {
+			return nil, errors.New("container not found")
+		}
+	}
+	tests := []jobTest{
+		{
+			job:   "exec-nginx-gateway-version",
+			files: map[string]string{"exec/nginx-gateway/ngf-nginx-gateway-6f8b9__nginx-gateway-version.txt": "nginx-gateway: /usr/bin/gateway --help"},
+		},
+		{
+			job:     "exec-nginx-gateway-version",
+			name:    "exec failure",
+			setup:   execFailure,
+			wantErr: true,
+		},
+		{
+			job:   "exec-nginx-t",
+			files: map[string]string{"exec/nginx-gateway/ngf-nginx-gateway-6f8b9__nginx-t.txt": "nginx: /usr/sbin/nginx -T"},
+		},
+		{
+			job:     "exec-nginx-t",
+			name:    "exec failure",
+			setup:   execFailure,
+			wantErr: true,
+		},
+		{
+			job: "crd-objects",
+			files: map[string]string{
+				"crds/nginx-gateway/nginxgateways.json": "ngf-config",
+				"crds/cluster-scoped/nginxproxies.json": "NginxProxyList",
+			},
+		},
+		{
+			job:     "crd-objects",
+			name:    "forbidden",
+			setup:   fail("list customresourcedefinitions"),
+			wantErr: true,
+		},
+		{
+			job: "gateway-api-objects",
+			files: map[string]string{
+				"crds/cluster-scoped/gatewayclasses_v1.json":      "gateway.nginx.org/nginx-gateway-controller",
+				"crds/cluster-scoped/gatewayclasses_v1beta1.json": "GatewayClassList",
+				"crds/nginx-gateway/gateways_v1.json":             `"name": "cafe"`,
+				"crds/nginx-gateway/gateways_v1beta1.json":        "GatewayList",
+				"crds/nginx-gateway/httproutes_v1.json":           `"name": "coffee"`,
+			},
+		},
+		{
+			job:     "gateway-api-objects",
+			name:    "forbidden",
+			setup:   fail("list customresourcedefinitions"),
+			wantErr: true,
+		},
+		{
+			job: "crd-status-summary",
+			files: map[string]string{
+				"crds/crd-status-summary.json": `"reason": "BackendNotFound"`,
+				"crds/crd-status-summary.txt":  "2 of 3 objects in Warning or Invalid state",
+			},
+		},
+		{
+			job:  "crd-status-summary",
+			name: "gateway api not installed",
+			setup: func(cluster *testcluster.Cluster) {
+				cluster.Definitions = nil
+				cluster.CustomObjects = nil
+			},
+			files: map[string]string{
+				"crds/crd-status-summary.json": "[]",
+				"crds/crd-status-summary.txt":  "0 of 0 objects",
+			},
+		},
+	}
+	runJobTests(t, "ngf", NGFJobList(), []string{"nginx-gateway"}, ngfCluster, tests)
+}
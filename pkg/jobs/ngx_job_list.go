@@ -21,37 +21,29 @@ package jobs
 import (
 	"context"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func NGXJobList() []Job {
 	jobList := []Job{
 		{
-			Name:    "exec-nginx-t",
-			Timeout: time.Second * 10,
+			Name:        "exec-nginx-t",
+			Description: "Run nginx -T in the NGINX pods to dump the NGINX configuration",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				command := []string{"/usr/sbin/nginx", "-T"}
-				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+				for _, pod := range productPods(dc, ctx) {
+					res, err := dc.PodExecutor(pod.Namespace, pod.Name, "nginx", command, ctx)
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						jobResult.Error = err
+						dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", "nginx", "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "nginx") {
-								res, err := dc.PodExecutor(namespace, pod.Name, "nginx", command, ctx)
-								if err != nil {
-									jobResult.Error = err
-									dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-								} else {
-									jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, pod.Name+"__nginx-t.txt")] = res
-								}
-							}
-						}
+						jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, pod.Name+"__nginx-t.txt")] = res
 					}
 				}
 				ch <- jobResult
@@ -0,0 +1,71 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func ngxCluster() testcluster.Cluster {
+	return testcluster.Cluster{
+		Objects: []runtime.Object{
+			testPod("web", "nginx-5c6d8", "nginx"),
+			testPod("web", "redis-8f7d6", "redis"),
+		},
+	}
+}
+
+func TestNGXJobList(t *testing.T) {
+	tests := []jobTest{
+		{
+			job:   "exec-nginx-t",
+			files: map[string]string{"exec/web/nginx-5c6d8__nginx-t.txt": "web/nginx-5c6d8/nginx: /usr/sbin/nginx -T"},
+		},
+		{
+			job:  "exec-nginx-t",
+			name: "exec failure",
+			setup: func(cluster *testcluster.Cluster) {
+				cluster.Exec = func(string, string, string, []string, context.Context) ([]byte, error) {
+					return nil, errors.New("container not found")
+				}
+			},
+			wantErr: true,
+		},
+		{
+			job:   "exec-nginx-t",
+			name:  "forbidden",
+			setup: fail("get pods"),
+		},
+		{
+			job:  "exec-nginx-t",
+			name: "pods found before collecting",
+			setup: func(cluster *testcluster.Cluster) {
+				cluster.Objects = append(cluster.Objects, testPod("web", "nginx-7f9b2", "nginx"))
+			},
+			pods:  []string{"web/nginx-5c6d8"},
+			files: map[string]string{"exec/web/nginx-5c6d8__nginx-t.txt": "web/nginx-5c6d8/nginx: /usr/sbin/nginx -T"},
+		},
+	}
+	runJobTests(t, "ngx", NGXJobList(), []string{"web"}, ngxCluster, tests)
+}
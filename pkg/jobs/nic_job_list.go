@@ -19,14 +19,17 @@ limitations under the License.
 package jobs
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
 	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 )
@@ -34,30 +37,23 @@ import (
 func NICJobList() []Job {
 	jobList := []Job{
 		{
-			Name:    "exec-nginx-ingress-version",
-			Timeout: time.Second * 10,
+			Name:        "exec-nginx-ingress-version",
+			Description: "Run nginx-ingress --version in the Ingress Controller pods",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				command := []string{"./nginx-ingress", "--version"}
-				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
-					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "ingress") {
-								for _, container := range pod.Spec.Containers {
-
-									res, err := dc.PodExecutor(namespace, pod.Name, container.Name, command, ctx)
-									if err != nil {
-										jobResult.Error = err
-										dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-									} else {
-										fileName := fmt.Sprintf("%s__%s__nginx-ingress-version.txt", pod.Name, container.Name)
-										jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, fileName)] = res
-									}
-								}
-							}
+				for _, pod := range productPods(dc, ctx) {
+					for _, container := range pod.Spec.Containers {
+						res, err := dc.PodExecutor(pod.Namespace, pod.Name, container.Name, command, ctx)
+						if err != nil {
+							jobResult.Error = err
+							dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err)
+						} else {
+							fileName := fmt.Sprintf("%s__%s__nginx-ingress-version.txt", pod.Name, container.Name)
+							jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, fileName)] = res
 						}
 					}
 				}
@@ -65,115 +61,342 @@ func NICJobList() []Job {
 			},
 		},
 		{
-			Name:    "exec-nginx-t",
-			Timeout: time.Second * 10,
+			Name:        "exec-nginx-t",
+			Description: "Run nginx -T in the Ingress Controller pods to dump the NGINX configuration",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				command := []string{"/usr/sbin/nginx", "-T"}
+				for _, pod := range productPods(dc, ctx) {
+					for _, container := range pod.Spec.Containers {
+						res, err := dc.PodExecutor(pod.Namespace, pod.Name, container.Name, command, ctx)
+						if err != nil {
+							jobResult.Error = err
+							dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err)
+						} else {
+							fileName := fmt.Sprintf("%s__%s__nginx-t.txt", pod.Name, container.Name)
+							jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, fileName)] = res
+						}
+					}
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "exec-agent-conf",
+			Description: "Read the NGINX Agent configuration in the Ingress Controller pods",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				command := []string{"cat", "/etc/nginx-agent/nginx-agent.conf"}
+				for _, pod := range productPods(dc, ctx) {
+					for _, container := range pod.Spec.Containers {
+						res, err := dc.PodExecutor(pod.Namespace, pod.Name, container.Name, command, ctx)
+						if err != nil {
+							jobResult.Error = err
+							dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err)
+						} else {
+							fileName := fmt.Sprintf("%s__%s__nginx-agent.conf", pod.Name, container.Name)
+							jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, fileName)] = res
+						}
+					}
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "exec-agent-version",
+			Description: "Run nginx-agent --version in the Ingress Controller pods",
+			Permissions: []string{"get pods", "create pods/exec"},
+			Timeout:     time.Second * 10,
+			PerPod:      true,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				command := []string{"/usr/bin/nginx-agent", "--version"}
+				for _, pod := range productPods(dc, ctx) {
+					for _, container := range pod.Spec.Containers {
+						res, err := dc.PodExecutor(pod.Namespace, pod.Name, container.Name, command, ctx)
+						if err != nil {
+							jobResult.Error = err
+							dc.Logger.ErrorContext(ctx, "Command execution failed", "command", command, "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err)
+						} else {
+							fileName := fmt.Sprintf("%s__%s__nginx-agent-version.txt", pod.Name, container.Name)
+							jobResult.Files[filepath.Join(dc.BaseDir, "exec", pod.Namespace, fileName)] = res
+						}
+					}
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "crd-objects",
+			Description: "Collect the Ingress Controller custom resources",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list *.k8s.nginx.org", "list *.appprotect.f5.com", "list *.appprotectdos.f5.com"},
+			Timeout:     time.Second * 10,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				collectCRDObjects(dc, ctx, crds.GetNICCRDList(), dc.AllCRDVersions, &jobResult)
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "ingress-list",
+			Description: "List the ingresses of the namespaces",
+			Permissions: []string{"list ingresses.networking.k8s.io"},
+			Timeout:     time.Second * 10,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
 				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+					result, err := dc.K8sCoreClientSet.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve ingress list", "namespace", namespace, "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "ingress") {
-								for _, container := range pod.Spec.Containers {
-									res, err := dc.PodExecutor(namespace, pod.Name, container.Name, command, ctx)
-									if err != nil {
-										jobResult.Error = err
-										dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-									} else {
-										fileName := fmt.Sprintf("%s__%s__nginx-t.txt", pod.Name, container.Name)
-										jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, fileName)] = res
-									}
-								}
-							}
-						}
+						jsonResult, _ := json.MarshalIndent(result, "", "  ")
+						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "ingresses.json")] = jsonResult
 					}
 				}
 				ch <- jobResult
 			},
 		},
 		{
-			Name:    "exec-agent-conf",
-			Timeout: time.Second * 10,
+			Name:        "ingressclass-list",
+			Description: "List the ingress classes of the cluster",
+			Permissions: []string{"list ingressclasses.networking.k8s.io"},
+			Timeout:     time.Second * 10,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				result, err := dc.K8sCoreClientSet.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+				if err != nil {
+					dc.Logger.ErrorContext(ctx, "Could not retrieve ingressclass list", "error", err)
+				} else {
+					jsonResult, _ := json.MarshalIndent(result, "", "  ")
+					jobResult.Files[filepath.Join(dc.BaseDir, "k8s", "ingressclasses.json")] = jsonResult
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "endpointslice-list",
+			Description: "List the endpoint slices of the namespaces",
+			Permissions: []string{"list endpointslices.discovery.k8s.io"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
-				command := []string{"cat", "/etc/nginx-agent/nginx-agent.conf"}
 				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+					result, err := dc.K8sCoreClientSet.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve endpointslice list", "namespace", namespace, "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "ingress") {
-								for _, container := range pod.Spec.Containers {
-									res, err := dc.PodExecutor(namespace, pod.Name, container.Name, command, ctx)
-									if err != nil {
-										jobResult.Error = err
-										dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-									} else {
-										fileName := fmt.Sprintf("%s__%s__nginx-agent.conf", pod.Name, container.Name)
-										jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, fileName)] = res
-									}
-								}
-							}
-						}
+						jsonResult, _ := json.MarshalIndent(result, "", "  ")
+						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "endpointslices.json")] = jsonResult
 					}
 				}
 				ch <- jobResult
 			},
 		},
 		{
-			Name:    "exec-agent-version",
-			Timeout: time.Second * 10,
+			Name:        "secret-list",
+			Description: "List the secrets of the namespaces, without their data",
+			Permissions: []string{"list secrets"},
+			Timeout:     time.Second * 10,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
-				command := []string{"/usr/bin/nginx-agent", "--version"}
 				for _, namespace := range dc.Namespaces {
-					pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+					result, err := dc.K8sCoreClientSet.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
 					if err != nil {
-						dc.Logger.Printf("\tCould not retrieve pod list for namespace %s: %v\n", namespace, err)
+						dc.Logger.ErrorContext(ctx, "Could not retrieve secret list", "namespace", namespace, "error", err)
 					} else {
-						for _, pod := range pods.Items {
-							if strings.Contains(pod.Name, "ingress") {
-								for _, container := range pod.Spec.Containers {
-									res, err := dc.PodExecutor(namespace, pod.Name, container.Name, command, ctx)
-									if err != nil {
-										jobResult.Error = err
-										dc.Logger.Printf("\tCommand execution %s failed for pod %s in namespace %s: %v\n", command, pod.Name, namespace, err)
-									} else {
-										fileName := fmt.Sprintf("%s__%s__nginx-agent-version.txt", pod.Name, container.Name)
-										jobResult.Files[filepath.Join(dc.BaseDir, "exec", namespace, fileName)] = res
-									}
-								}
-							}
+						for i := range result.Items {
+							redactSecret(&result.Items[i])
 						}
+						jsonResult, _ := json.MarshalIndent(result, "", "  ")
+						jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "secrets.json")] = jsonResult
 					}
 				}
 				ch <- jobResult
 			},
 		},
 		{
-			Name:    "crd-objects",
-			Timeout: time.Second * 10,
+			Name:        "backend-services",
+			Description: "Collect the services referenced by virtual servers and virtual server routes from other namespaces",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list virtualservers.k8s.nginx.org", "list virtualserverroutes.k8s.nginx.org", "get services", "list endpointslices.discovery.k8s.io"},
+			Timeout:     time.Second * 30,
 			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
 				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				// Ingresses are not followed, as they can only reference services of their own namespace
+				var refs []serviceRef
 				for _, namespace := range dc.Namespaces {
-					for _, crd := range crds.GetNICCRDList() {
-						result, err := dc.QueryCRD(crd, namespace, ctx)
-						if err != nil {
-							dc.Logger.Printf("\tCRD %s.%s/%s could not be collected in namespace %s: %v\n", crd.Resource, crd.Group, crd.Version, namespace, err)
-						} else {
-							var jsonResult bytes.Buffer
-							_ = json.Indent(&jsonResult, result, "", "  ")
-							jobResult.Files[filepath.Join(dc.BaseDir, "crds", namespace, crd.Resource+".json")] = jsonResult.Bytes()
-						}
+					refs = append(refs, virtualServerBackendRefs(dc, ctx, namespace)...)
+				}
+
+				// Backends living in namespaces passed via -n are already covered by
+				// service-list and endpointslice-list, only the remaining ones are fetched here.
+				services := make(map[string]*corev1.ServiceList)
+				endpointSlices := make(map[string]*discoveryv1.EndpointSliceList)
+				for _, ref := range uniqueServiceRefs(refs) {
+					if slices.Contains(dc.Namespaces, ref.Namespace) {
+						continue
+					}
+					if services[ref.Namespace] == nil {
+						services[ref.Namespace] = &corev1.ServiceList{}
+						endpointSlices[ref.Namespace] = &discoveryv1.EndpointSliceList{}
+					}
+					service, err := dc.K8sCoreClientSet.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+					if err != nil {
+						dc.Logger.ErrorContext(ctx, "Could not retrieve backend service", "namespace", ref.Namespace, "service", ref.Name, "error", err)
+						continue
+					}
+					services[ref.Namespace].Items = append(services[ref.Namespace].Items, *service)
+					slicesResult, err := dc.K8sCoreClientSet.DiscoveryV1().EndpointSlices(ref.Namespace).List(ctx, metav1.ListOptions{
+						LabelSelector: discoveryv1.LabelServiceName + "=" + ref.Name,
+					})
+					if err != nil {
+						dc.Logger.ErrorContext(ctx, "Could not retrieve endpointslices for backend service", "namespace", ref.Namespace, "service", ref.Name, "error", err)
+						continue
 					}
+					endpointSlices[ref.Namespace].Items = append(endpointSlices[ref.Namespace].Items, slicesResult.Items...)
 				}
+				for namespace, serviceList := range services {
+					jsonServices, _ := json.MarshalIndent(serviceList, "", "  ")
+					jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "backend-services.json")] = jsonServices
+					jsonSlices, _ := json.MarshalIndent(endpointSlices[namespace], "", "  ")
+					jobResult.Files[filepath.Join(dc.BaseDir, "resources", namespace, "backend-endpointslices.json")] = jsonSlices
+				}
+				ch <- jobResult
+			},
+		},
+		{
+			Name:        "crd-status-summary",
+			Description: "Summarize the status of the virtual servers, routes, policies and transport servers",
+			Permissions: []string{"list customresourcedefinitions.apiextensions.k8s.io", "list *.k8s.nginx.org"},
+			Timeout:     time.Second * 30,
+			Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+				jobResult := JobResult{Files: make(map[string][]byte), Error: nil}
+				statusResources := []string{"virtualservers", "virtualserverroutes", "policies", "transportservers"}
+				crdList := slices.DeleteFunc(crds.GetNICCRDList(), func(crd crds.Crd) bool {
+					return !slices.Contains(statusResources, crd.Resource)
+				})
+				summarizeCRDStatus(dc, ctx, crdList, &jobResult)
 				ch <- jobResult
 			},
 		},
 	}
 	return jobList
 }
+
+// serviceRef identifies a backend service referenced by a VirtualServer or a VirtualServerRoute.
+type serviceRef struct {
+	Namespace string
+	Name      string
+}
+
+// virtualServerList holds the subset of VirtualServer and VirtualServerRoute
+// fields needed to find their backends.
+type virtualServerList struct {
+	Items []struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Upstreams []struct {
+				Service string `json:"service"`
+			} `json:"upstreams"`
+			Routes []struct {
+				Route string `json:"route"`
+			} `json:"routes"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// redactSecret drops the secret payload, including any copy of it kept by kubectl apply.
+func redactSecret(secret *corev1.Secret) {
+	secret.Data = nil
+	secret.StringData = nil
+	delete(secret.Annotations, corev1.LastAppliedConfigAnnotation)
+}
+
+// parseRef splits a "namespace/name" reference, defaulting to the given namespace.
+func parseRef(ref string, namespace string) serviceRef {
+	if ns, name, found := strings.Cut(ref, "/"); found {
+		return serviceRef{Namespace: ns, Name: name}
+	}
+	return serviceRef{Namespace: namespace, Name: ref}
+}
+
+// virtualServerBackendRefs returns the upstream services of the VirtualServers in the namespace,
+// following routes delegated to VirtualServerRoutes in other namespaces.
+func virtualServerBackendRefs(dc *data_collector.DataCollector, ctx context.Context, namespace string) []serviceRef {
+	var refs []serviceRef
+	virtualServers, err := queryVirtualServers(dc, ctx, "virtualservers", namespace)
+	if err != nil {
+		return refs
+	}
+	routeNamespaces := make(map[string]bool)
+	for _, vs := range virtualServers.Items {
+		for _, upstream := range vs.Spec.Upstreams {
+			refs = append(refs, parseRef(upstream.Service, vs.Metadata.Namespace))
+		}
+		for _, route := range vs.Spec.Routes {
+			if route.Route != "" {
+				routeNamespaces[parseRef(route.Route, vs.Metadata.Namespace).Namespace] = true
+			}
+		}
+	}
+	for routeNamespace := range routeNamespaces {
+		routes, err := queryVirtualServers(dc, ctx, "virtualserverroutes", routeNamespace)
+		if err != nil {
+			continue
+		}
+		for _, vsr := range routes.Items {
+			for _, upstream := range vsr.Spec.Upstreams {
+				refs = append(refs, parseRef(upstream.Service, vsr.Metadata.Namespace))
+			}
+		}
+	}
+	return refs
+}
+
+func queryVirtualServers(dc *data_collector.DataCollector, ctx context.Context, resource string, namespace string) (*virtualServerList, error) {
+	crdList := slices.DeleteFunc(crds.GetNICCRDList(), func(crd crds.Crd) bool { return crd.Resource != resource })
+	resolved, _, err := dc.ResolveCRDs(crdList, false, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("CRD %s is not installed", resource)
+	}
+	crd := resolved[0]
+	result, err := dc.QueryCRD(crd, namespace, ctx)
+	if err != nil {
+		dc.Logger.ErrorContext(ctx, "CRD could not be collected", "crd", crd.Resource+"."+crd.Group, "version", crd.Version, "namespace", namespace, "error", err)
+		return nil, err
+	}
+	var list virtualServerList
+	if err = json.Unmarshal(result, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func uniqueServiceRefs(refs []serviceRef) []serviceRef {
+	seen := make(map[serviceRef]bool)
+	var unique []serviceRef
+	for _, ref := range refs {
+		if ref.Name == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		unique = append(unique, ref)
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].Namespace != unique[j].Namespace {
+			return unique[i].Namespace < unique[j].Namespace
+		}
+		return unique[i].Name < unique[j].Name
+	})
+	return unique
+}
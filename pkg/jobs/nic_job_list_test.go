@@ -0,0 +1,184 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// nicCluster runs the Ingress Controller in the default namespace, with a virtual server
+// delegating a route to the coffee namespace.
+func nicCluster() testcluster.Cluster {
+	return testcluster.Cluster{
+		Objects: []runtime.Object{
+			testPod("default", "nginx-ingress-7d9c5", "nginx-ingress"),
+			testPod("default", "tea-5c8d7", "tea"),
+			&networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cafe-ingress"},
+				Spec: networkingv1.IngressSpec{
+					DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "tea-svc"}},
+				},
+			},
+			&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tea-svc-m4z7q", Labels: map[string]string{discoveryv1.LabelServiceName: "tea-svc"}},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "default",
+					Name:        "cafe-secret",
+					Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: `{"data":{"tls.key":"c2VjcmV0LWtleQ=="}}`},
+				},
+				Data: map[string][]byte{"tls.key": []byte("secret-key")},
+			},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "coffee", Name: "coffee-svc"}},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "coffee", Name: "coffee-svc-x8k2p", Labels: map[string]string{discoveryv1.LabelServiceName: "coffee-svc"}},
+			},
+		},
+		Definitions: []runtime.Object{
+			testCRD("k8s.nginx.org", "virtualservers", "VirtualServer", apiextensionsv1.NamespaceScoped, "v1"),
+			testCRD("k8s.nginx.org", "virtualserverroutes", "VirtualServerRoute", apiextensionsv1.NamespaceScoped, "v1"),
+			testCRD("k8s.nginx.org", "policies", "Policy", apiextensionsv1.NamespaceScoped, "v1"),
+		},
+		CustomObjects: []*unstructured.Unstructured{
+			testObject("k8s.nginx.org/v1", "VirtualServer", "default", "cafe",
+				map[string]interface{}{
+					"upstreams": []interface{}{map[string]interface{}{"service": "tea-svc"}},
+					"routes":    []interface{}{map[string]interface{}{"route": "coffee/coffee-vsr"}},
+				},
+				map[string]interface{}{"state": "Valid", "reason": "AddedOrUpdated"}),
+			testObject("k8s.nginx.org/v1", "VirtualServerRoute", "coffee", "coffee-vsr",
+				map[string]interface{}{"upstreams": []interface{}{map[string]interface{}{"service": "coffee-svc"}}},
+				map[string]interface{}{"state": "Valid", "reason": "AddedOrUpdated"}),
+			testObject("k8s.nginx.org/v1", "Policy", "default", "rate-limit",
+				nil,
+				map[string]interface{}{"state": "Invalid", "reason": "Rejected", "message": "rate is invalid"}),
+		},
+	}
+}
+
+func TestNICJobList(t *testing.T) {
+	execFailure := func(cluster *testcluster.Cluster) {
+		cluster.Exec = func(string, string, string, []string, context.Context) ([]byte, error) {
+			return nil, errors.New("container not found")
+		}
+	}
+	tests := []jobTest{
+		{
+			job:   "exec-nginx-ingress-version",
+			files: map[string]string{"exec/default/nginx-ingress-7d9c5__nginx-ingress__nginx-ingress-version.txt": "nginx-ingress: ./nginx-ingress --version"},
+		},
+		{
+			job:     "exec-nginx-ingress-version",
+			name:    "exec failure",
+			setup:   execFailure,
+			wantErr: true,
+		},
+		{
+			job:   "exec-nginx-t",
+			files: map[string]string{"exec/default/nginx-ingress-7d9c5__nginx-ingress__nginx-t.txt": "nginx-ingress: /usr/sbin/nginx -T"},
+		},
+		{
+			job:     "exec-nginx-t",
+			name:    "exec failure",
+			setup:   execFailure,
+			wantErr: true,
+		},
+		{
+			job:   "exec-agent-conf",
+			files: map[string]string{"exec/default/nginx-ingress-7d9c5__nginx-ingress__nginx-agent.conf": "cat /etc/nginx-agent/nginx-agent.conf"},
+		},
+		{
+			job:   "exec-agent-version",
+			files: map[string]string{"exec/default/nginx-ingress-7d9c5__nginx-ingress__nginx-agent-version.txt": "/usr/bin/nginx-agent --version"},
+		},
+		{
+			job: "crd-objects",
+			files: map[string]string{
+				"crds/default/virtualservers.json":      `"name": "cafe"`,
+				"crds/default/virtualserverroutes.json": "VirtualServerRouteList",
+				"crds/default/policies.json":            `"name": "rate-limit"`,
+			},
+		},
+		{
+			job:     "crd-objects",
+			name:    "forbidden",
+			setup:   fail("list customresourcedefinitions"),
+			wantErr: true,
+		},
+		{
+			job:   "ingress-list",
+			files: map[string]string{"resources/default/ingresses.json": "cafe-ingress"},
+		},
+		{
+			job:   "ingressclass-list",
+			files: map[string]string{"k8s/ingressclasses.json": `"name": "nginx"`},
+		},
+		{
+			job:   "endpointslice-list",
+			files: map[string]string{"resources/default/endpointslices.json": "tea-svc-m4z7q"},
+		},
+		{
+			job:      "secret-list",
+			files:    map[string]string{"resources/default/secrets.json": "cafe-secret"},
+			excluded: "c2VjcmV0LWtleQ",
+		},
+		{
+			job: "backend-services",
+			files: map[string]string{
+				"resources/coffee/backend-services.json":       "coffee-svc",
+				"resources/coffee/backend-endpointslices.json": "coffee-svc-x8k2p",
+			},
+		},
+		{
+			job:  "backend-services",
+			name: "without virtual servers",
+			setup: func(cluster *testcluster.Cluster) {
+				cluster.Definitions = nil
+				cluster.CustomObjects = nil
+			},
+		},
+		{
+			job: "crd-status-summary",
+			files: map[string]string{
+				"crds/crd-status-summary.json": `"reason": "Rejected"`,
+				"crds/crd-status-summary.txt":  "1 of 2 objects in Warning or Invalid state",
+			},
+		},
+		{
+			job:     "crd-status-summary",
+			name:    "forbidden",
+			setup:   fail("list customresourcedefinitions"),
+			wantErr: true,
+		},
+	}
+	runJobTests(t, "nic", NICJobList(), []string{"default"}, nicCluster, tests)
+}
@@ -0,0 +1,157 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Product is a product the support package can be collected for, added to the registry with Register.
+type Product struct {
+	// Name selects the product, such as nic with --product
+	Name string
+	// Detect returns the pods of the product in the namespaces of the collector, as namespace/name;
+	// the collection stops before running any job when there are none
+	Detect func(dc *data_collector.DataCollector, ctx context.Context) ([]string, error)
+	// CRDs returns the custom resources of the product. Unless Jobs has a crd-objects job, one
+	// collecting them is added to the jobs of the product.
+	CRDs func() []crds.Crd
+	// Jobs returns the jobs of the product, run after the common jobs
+	Jobs func() []Job
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Product
+)
+
+func init() {
+	Register(Product{Name: "nic", Detect: PodNameDetector("ingress"), CRDs: crds.GetNICCRDList, Jobs: NICJobList})
+	Register(Product{Name: "ngf", Detect: PodNameDetector("nginx-gateway"), CRDs: crds.GetNGFCRDList, Jobs: NGFJobList})
+	Register(Product{Name: "ngx", Detect: PodNameDetector("nginx"), Jobs: NGXJobList})
+}
+
+// Register adds a product to the registry, typically from an init function. It panics when the
+// product has no name, detection or jobs, or when a product with the same name is registered.
+func Register(product Product) {
+	if product.Name == "" || product.Detect == nil || product.Jobs == nil {
+		panic("jobs: a product needs a name, a detection function and jobs")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if slices.ContainsFunc(registry, func(registered Product) bool { return registered.Name == product.Name }) {
+		panic(fmt.Sprintf("jobs: product %s registered twice", product.Name))
+	}
+	registry = append(registry, product)
+}
+
+// Products returns the names of the registered products, in registration order.
+func Products() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return productNames(registry)
+}
+
+// LookupProduct returns the registered product with the name.
+func LookupProduct(name string) (Product, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	index := slices.IndexFunc(registry, func(product Product) bool { return product.Name == name })
+	if index < 0 {
+		return Product{}, fmt.Errorf("product must be in the following list: %v", productNames(registry))
+	}
+	return registry[index], nil
+}
+
+func productNames(products []Product) []string {
+	names := make([]string, len(products))
+	for i, product := range products {
+		names[i] = product.Name
+	}
+	return names
+}
+
+// ProductJobList returns the jobs run for a product: the common jobs followed by its own.
+func ProductJobList(product string) ([]Job, error) {
+	registered, err := LookupProduct(product)
+	if err != nil {
+		return nil, err
+	}
+	jobList := slices.Concat(CommonJobList(), registered.Jobs())
+	if registered.CRDs != nil && !slices.ContainsFunc(jobList, func(job Job) bool { return job.Name == "crd-objects" }) {
+		jobList = append(jobList, CRDObjectsJob(registered.CRDs))
+	}
+	return jobList, nil
+}
+
+// FindProductPods returns the pods of the product in the namespaces of the collector, as
+// namespace/name, to check the product is deployed there before collecting anything.
+func FindProductPods(dc *data_collector.DataCollector, product string, ctx context.Context) ([]string, error) {
+	registered, err := LookupProduct(product)
+	if err != nil {
+		return nil, err
+	}
+	return registered.Detect(dc, ctx)
+}
+
+// productPods returns the pods of the product found before collecting, which the exec jobs run
+// their commands in. The pods that cannot be retrieved anymore are logged and left out.
+func productPods(dc *data_collector.DataCollector, ctx context.Context) []corev1.Pod {
+	var pods []corev1.Pod
+	for _, productPod := range dc.ProductPods {
+		namespace, name, _ := strings.Cut(productPod, "/")
+		pod, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			dc.Logger.ErrorContext(ctx, "Could not retrieve pod", "namespace", namespace, "pod", name, "error", err)
+			continue
+		}
+		pods = append(pods, *pod)
+	}
+	return pods
+}
+
+// PodNameDetector detects a product by the pods whose name holds podName, which are also the
+// pods the exec jobs of the product run their commands in.
+func PodNameDetector(podName string) func(dc *data_collector.DataCollector, ctx context.Context) ([]string, error) {
+	return func(dc *data_collector.DataCollector, ctx context.Context) ([]string, error) {
+		var found []string
+		for _, namespace := range dc.Namespaces {
+			pods, err := dc.K8sCoreClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("could not retrieve pod list for namespace %s: %w", namespace, err)
+			}
+			for _, pod := range pods.Items {
+				if strings.Contains(pod.Name, podName) {
+					found = append(found, path.Join(namespace, pod.Name))
+				}
+			}
+		}
+		return found, nil
+	}
+}
@@ -0,0 +1,158 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package jobs
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/internal/testcluster"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/crds"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// certManager is a product registered by the tests, with a job of its own and the objects of
+// its CRDs collected by the job the registry adds.
+func certManager() Product {
+	return Product{
+		Name:   "cert-manager",
+		Detect: PodNameDetector("cert-manager"),
+		CRDs: func() []crds.Crd {
+			return []crds.Crd{{Resource: "certificates", Group: "cert-manager.io", Version: "v1"}}
+		},
+		Jobs: func() []Job {
+			return []Job{{
+				Name: "cert-manager-version",
+				Execute: func(dc *data_collector.DataCollector, ctx context.Context, ch chan JobResult) {
+					ch <- JobResult{}
+				},
+			}}
+		},
+	}
+}
+
+// register adds the product to the registry for the duration of the test.
+func register(t *testing.T, product Product) {
+	registryMu.RLock()
+	registered := slices.Clone(registry)
+	registryMu.RUnlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = registered
+		registryMu.Unlock()
+	})
+	Register(product)
+}
+
+func TestRegister(t *testing.T) {
+	register(t, certManager())
+
+	if products := Products(); !slices.Equal(products, []string{"nic", "ngf", "ngx", "cert-manager"}) {
+		t.Errorf("unexpected products %v", products)
+	}
+	jobList, err := ProductJobList("cert-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, job := range jobList[len(CommonJobList()):] {
+		names = append(names, job.Name)
+	}
+	if !slices.Equal(names, []string{"cert-manager-version", "crd-objects"}) {
+		t.Errorf("unexpected product jobs %v", names)
+	}
+	crdObjects := jobList[len(jobList)-1]
+	if !slices.Contains(crdObjects.Permissions, "list *.cert-manager.io") {
+		t.Errorf("unexpected permissions %v", crdObjects.Permissions)
+	}
+
+	dc := newTestCollector(t, []string{"cert-manager"}, testcluster.Cluster{
+		Objects: []runtime.Object{
+			testPod("cert-manager", "cert-manager-7b8d9", "cert-manager-controller"),
+			testPod("cert-manager", "webhook-5f6c7", "webhook"),
+		},
+		Definitions:   []runtime.Object{testCRD("cert-manager.io", "certificates", "Certificate", apiextensionsv1.NamespaceScoped, "v1")},
+		CustomObjects: []*unstructured.Unstructured{testObject("cert-manager.io/v1", "Certificate", "cert-manager", "cafe-tls", nil, nil)},
+	})
+	pods, err := FindProductPods(dc, "cert-manager", context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pods, []string{"cert-manager/cert-manager-7b8d9"}) {
+		t.Errorf("unexpected pods %v", pods)
+	}
+	if err = crdObjects.Collect(dc, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if content := testcluster.ReadFiles(t, dc.BaseDir)["crds/cert-manager/certificates.json"]; !strings.Contains(content, "cafe-tls") {
+		t.Errorf("certificates not collected:\n%s", content)
+	}
+}
+
+func TestRegisterOwnCRDObjectsJob(t *testing.T) {
+	jobList, err := ProductJobList("nic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, job := range jobList {
+		if job.Name == "crd-objects" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("%d crd-objects jobs, expected the one of the product", count)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	duplicate := certManager()
+	duplicate.Name = "nic"
+	noDetection := certManager()
+	noDetection.Detect = nil
+	tests := []struct {
+		name    string
+		product Product
+	}{
+		{"duplicate", duplicate},
+		{"no name", Product{Detect: PodNameDetector("x"), Jobs: NGXJobList}},
+		{"no detection", noDetection},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			register(t, test.product)
+		})
+	}
+}
+
+func TestUnknownProduct(t *testing.T) {
+	if _, err := ProductJobList("cert-manager"); err == nil || !strings.Contains(err.Error(), "[nic ngf ngx]") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+)
+
+const (
+	FileName          = "manifest.json"
+	SignatureFileName = "manifest.json.sig"
+	SignatureEd25519  = "ed25519"
+)
+
+// File is the manifest entry of a file of the support package, relative to its root directory.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// RetryAttempt records a failed attempt of a request made while collecting the support package,
+// so that data collected after retries, or missing after failed ones, can be told apart.
+type RetryAttempt struct {
+	Job       string `json:"job,omitempty"`
+	Operation string `json:"operation"`
+	Attempt   int    `json:"attempt"`
+	Error     string `json:"error"`
+	// WaitSeconds is the delay before the next attempt, zero when the request was not retried
+	WaitSeconds float64   `json:"waitSeconds"`
+	Time        time.Time `json:"time"`
+}
+
+// CollectorRun records a run of an external collector executable, whose files are under collectors/<name>.
+type CollectorRun struct {
+	Name       string `json:"name"`
+	Executable string `json:"executable"`
+	// ExitCode is -1 when the collector did not start or was killed on timeout
+	ExitCode        int     `json:"exitCode"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+	// Output is the end of what the collector wrote to stdout and stderr
+	Output string   `json:"output,omitempty"`
+	Files  []string `json:"files"`
+}
+
+// Manifest describes the content of a support package.
+type Manifest struct {
+	Version    string         `json:"version"`
+	Build      string         `json:"build"`
+	Product    string         `json:"product"`
+	Created    time.Time      `json:"created"`
+	Files      []File         `json:"files"`
+	Retries    []RetryAttempt `json:"retries,omitempty"`
+	Collectors []CollectorRun `json:"collectors,omitempty"`
+}
+
+// Signature is the content of SignatureFileName, an ed25519 signature over the manifest bytes.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// Sign returns the content of the signature file for the given manifest bytes.
+func Sign(manifest []byte, key ed25519.PrivateKey) ([]byte, error) {
+	signature := Signature{
+		Algorithm: SignatureEd25519,
+		PublicKey: EncodePublicKey(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	}
+	return json.MarshalIndent(signature, "", "  ")
+}
+
+// EncodePublicKey returns the base64 form of a public key, as printed for ephemeral keys and
+// accepted by ParsePublicKey.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey reads an ed25519 public key, either in base64 or as the path of a PEM file.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	if raw, err := base64.StdEncoding.DecodeString(value); err == nil && len(raw) == ed25519.PublicKeySize {
+		return raw, nil
+	}
+	pemBytes, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a base64 ed25519 public key nor a readable key file: %w", value, err)
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", value)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", value)
+	}
+	return publicKey, nil
+}
+
+// ParsePrivateKey reads an ed25519 private key from a PKCS #8 PEM file,
+// as generated by "openssl genpkey -algorithm ed25519".
+func ParsePrivateKey(path string) (ed25519.PrivateKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return privateKey, nil
+}
+
+// Report is the outcome of verifying a support package against its manifest.
+type Report struct {
+	Manifest *Manifest
+	// Verified is the number of files whose hash matches the manifest
+	Verified int
+	// Missing files are listed in the manifest but absent from the archive
+	Missing []string
+	// Mismatched files have a different size or hash than in the manifest
+	Mismatched []string
+	// Unexpected files are in the archive but not in the manifest
+	Unexpected []string
+	// Signed is set when the archive holds a signature, SignedBy is the key it was verified with
+	Signed   bool
+	SignedBy ed25519.PublicKey
+}
+
+// Valid tells whether every file matches the manifest.
+func (r *Report) Valid() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0 && len(r.Unexpected) == 0
+}
+
+// Verify checks every file of the archive at path against its manifest and, when the archive is
+// signed, the signature of the manifest. The signature must be made by publicKey when given,
+// otherwise the public key embedded in the signature file is used, which only proves integrity.
+func Verify(path string, publicKey ed25519.PublicKey) (*Report, error) {
+	var manifestBytes, signatureBytes []byte
+	found := make(map[string]File)
+	err := archive.Walk(path, func(name string, r io.Reader) error {
+		// Entries are stored under the root directory of the support package
+		_, relativePath, _ := strings.Cut(name, "/")
+		switch relativePath {
+		case FileName:
+			var err error
+			manifestBytes, err = io.ReadAll(r)
+			return err
+		case SignatureFileName:
+			var err error
+			signatureBytes, err = io.ReadAll(r)
+			return err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, r)
+		if err != nil {
+			return err
+		}
+		found[relativePath] = File{Path: relativePath, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifestBytes == nil {
+		return nil, fmt.Errorf("%s has no %s", path, FileName)
+	}
+
+	report := &Report{Manifest: &Manifest{}}
+	if err = json.Unmarshal(manifestBytes, report.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	for _, file := range report.Manifest.Files {
+		actual, ok := found[file.Path]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, file.Path)
+		case actual != file:
+			report.Mismatched = append(report.Mismatched, file.Path)
+		default:
+			report.Verified++
+		}
+		delete(found, file.Path)
+	}
+	for name := range found {
+		report.Unexpected = append(report.Unexpected, name)
+	}
+	slices.Sort(report.Unexpected)
+
+	if signatureBytes == nil {
+		if publicKey != nil {
+			return report, errors.New("the support package is not signed")
+		}
+		return report, nil
+	}
+	report.Signed = true
+	report.SignedBy, err = verifySignature(manifestBytes, signatureBytes, publicKey)
+	return report, err
+}
+
+func verifySignature(manifest []byte, signatureBytes []byte, publicKey ed25519.PublicKey) (ed25519.PublicKey, error) {
+	var signature Signature
+	if err := json.Unmarshal(signatureBytes, &signature); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SignatureFileName, err)
+	}
+	if signature.Algorithm != SignatureEd25519 {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", signature.Algorithm)
+	}
+	embeddedKey, err := base64.StdEncoding.DecodeString(signature.PublicKey)
+	if err != nil || len(embeddedKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key in %s", SignatureFileName)
+	}
+	if publicKey == nil {
+		publicKey = embeddedKey
+	}
+	rawSignature, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature in %s: %w", SignatureFileName, err)
+	}
+	if !ed25519.Verify(publicKey, manifest, rawSignature) {
+		return nil, errors.New("the manifest signature does not match the public key")
+	}
+	return publicKey, nil
+}
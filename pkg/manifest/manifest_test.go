@@ -0,0 +1,191 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+)
+
+// bundleFiles are the files of the support packages of the tests, besides the manifest.
+var bundleFiles = map[string]string{
+	"resources/default/pods.json":     `{"items":[]}`,
+	"exec/default/nginx__nginx-t.txt": "nginx: configuration file /etc/nginx/nginx.conf test is successful",
+}
+
+// manifestOf returns the manifest of files.
+func manifestOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	m := Manifest{Version: "0.0.1", Product: "nic", Created: time.Date(2024, 3, 25, 16, 42, 47, 0, time.UTC)}
+	for path, content := range files {
+		hash := sha256.Sum256([]byte(content))
+		m.Files = append(m.Files, File{Path: path, Size: int64(len(content)), SHA256: hex.EncodeToString(hash[:])})
+	}
+	slices.SortFunc(m.Files, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+	manifestBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifestBytes
+}
+
+// writeBundle writes a tar.gz support package holding entries, under its root directory.
+func writeBundle(t *testing.T, entries map[string][]byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	aw, err := archive.NewWriter(&buf, archive.TarGz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range entries {
+		if err = aw.WriteFile(filepath.Join("nic-supportpkg", name), int64(len(content)), time.Now(), bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "nic-supportpkg.tar.gz")
+	if err = os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	publicKey, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestBytes := manifestOf(t, bundleFiles)
+	signature, err := Sign(manifestBytes, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperedManifest := bytes.Replace(manifestBytes, []byte(`"product": "nic"`), []byte(`"product": "ngf"`), 1)
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		manifest  []byte
+		signature []byte
+		publicKey ed25519.PublicKey
+		// wantErr is part of the expected error, empty when none is
+		wantErr    string
+		invalid    bool
+		mismatched []string
+		missing    []string
+		unexpected []string
+		signed     bool
+	}{
+		{name: "signed", files: bundleFiles, manifest: manifestBytes, signature: signature, publicKey: publicKey, signed: true},
+		{name: "signed, embedded key", files: bundleFiles, manifest: manifestBytes, signature: signature, signed: true},
+		{name: "unsigned", files: bundleFiles, manifest: manifestBytes},
+		{
+			name: "tampered file",
+			files: map[string]string{
+				"resources/default/pods.json":     `{"items":[{"metadata":{"name":"forged"}}]}`,
+				"exec/default/nginx__nginx-t.txt": bundleFiles["exec/default/nginx__nginx-t.txt"],
+			},
+			manifest: manifestBytes, signature: signature, publicKey: publicKey, signed: true,
+			invalid: true, mismatched: []string{"resources/default/pods.json"},
+		},
+		{
+			name:     "missing and unexpected files",
+			files:    map[string]string{"resources/default/pods.json": bundleFiles["resources/default/pods.json"], "notes.txt": "added"},
+			manifest: manifestBytes, signature: signature, publicKey: publicKey, signed: true,
+			invalid: true, missing: []string{"exec/default/nginx__nginx-t.txt"}, unexpected: []string{"notes.txt"},
+		},
+		{name: "tampered manifest", files: bundleFiles, manifest: tamperedManifest, signature: signature, publicKey: publicKey, wantErr: "does not match"},
+		{name: "tampered manifest, embedded key", files: bundleFiles, manifest: tamperedManifest, signature: signature, wantErr: "does not match"},
+		{name: "wrong public key", files: bundleFiles, manifest: manifestBytes, signature: signature, publicKey: otherPublicKey, wantErr: "does not match"},
+		{name: "unsigned with public key", files: bundleFiles, manifest: manifestBytes, publicKey: publicKey, wantErr: "not signed"},
+		{name: "invalid signature file", files: bundleFiles, manifest: manifestBytes, signature: []byte("{"), wantErr: "invalid " + SignatureFileName},
+		{name: "no manifest", files: bundleFiles, wantErr: "has no " + FileName},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := make(map[string][]byte)
+			for path, content := range test.files {
+				entries[path] = []byte(content)
+			}
+			if test.manifest != nil {
+				entries[FileName] = test.manifest
+			}
+			if test.signature != nil {
+				entries[SignatureFileName] = test.signature
+			}
+
+			report, err := Verify(writeBundle(t, entries), test.publicKey)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, expected one with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Valid() == test.invalid {
+				t.Errorf("valid is %t", report.Valid())
+			}
+			if !slices.Equal(report.Mismatched, test.mismatched) || !slices.Equal(report.Missing, test.missing) || !slices.Equal(report.Unexpected, test.unexpected) {
+				t.Errorf("unexpected report: mismatched %v, missing %v, unexpected %v", report.Mismatched, report.Missing, report.Unexpected)
+			}
+			if report.Signed != test.signed {
+				t.Errorf("signed is %t", report.Signed)
+			}
+			if test.signed && !report.SignedBy.Equal(publicKey) {
+				t.Errorf("signed by %s", EncodePublicKey(report.SignedBy))
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePublicKey(EncodePublicKey(publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(publicKey) {
+		t.Error("the parsed key differs from the encoded one")
+	}
+	if _, err = ParsePublicKey(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
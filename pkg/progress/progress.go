@@ -0,0 +1,61 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package progress
+
+import (
+	"context"
+	"time"
+)
+
+type EventType int
+
+const (
+	// JobStarted is emitted when a job starts running
+	JobStarted EventType = iota
+	// JobPod is emitted when a job starts working on the pod in Detail
+	JobPod
+	// JobWrote is emitted when a job adds Bytes to the support package
+	JobWrote
+	// JobFinished is emitted when a job ends, with Err set when it failed
+	JobFinished
+)
+
+// Event reports the progress of a job.
+type Event struct {
+	Type   EventType
+	Job    string
+	Time   time.Time
+	Detail string
+	Bytes  int
+	Err    error
+}
+
+type jobKey struct{}
+
+// WithJob returns a context carrying the name of the running job, for events emitted deeper
+// in the call stack such as pod executions.
+func WithJob(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, jobKey{}, name)
+}
+
+// JobFromContext returns the name of the job running with ctx, or an empty string.
+func JobFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(jobKey{}).(string)
+	return name
+}
@@ -0,0 +1,218 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package progress
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+type jobState struct {
+	name   string
+	start  time.Time
+	end    time.Time
+	bytes  int64
+	detail string
+	err    error
+}
+
+func (j *jobState) duration() time.Duration {
+	if j.end.IsZero() {
+		return time.Since(j.start)
+	}
+	return j.end.Sub(j.start)
+}
+
+// status is shown on a single line, as the lines of the running jobs are erased by counting them.
+func (j *jobState) status() string {
+	if j.err != nil {
+		return "Error: " + strings.Join(strings.Fields(j.err.Error()), " ")
+	}
+	return "OK"
+}
+
+// Renderer displays the progress of the jobs from their events. On a terminal, running jobs are
+// shown with a spinner, their elapsed time, the bytes they collected and the pod they work on;
+// otherwise one line is printed when each job ends. Close prints a timing table of all jobs.
+type Renderer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	tty      bool
+	width    int
+	running  []*jobState
+	finished []*jobState
+	// printed is the number of finished jobs already printed above the running ones
+	printed int
+	// lines is the number of lines of running jobs drawn last
+	lines int
+	frame int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewTTYRenderer returns a renderer redrawing the running jobs on a terminal of the given width.
+func NewTTYRenderer(w io.Writer, width int) *Renderer {
+	r := &Renderer{w: w, tty: true, width: width, stop: make(chan struct{}), done: make(chan struct{})}
+	go r.refresh()
+	return r
+}
+
+// NewPlainRenderer returns a renderer printing one line per finished job, for logs and pipes.
+func NewPlainRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w}
+}
+
+// Handle records an event, and is safe to call from concurrent jobs.
+func (r *Renderer) Handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.Type == JobStarted {
+		r.running = append(r.running, &jobState{name: event.Job, start: event.Time})
+		return
+	}
+	index := -1
+	for i, job := range r.running {
+		if job.name == event.Job {
+			index = i
+		}
+	}
+	if index < 0 {
+		return
+	}
+	job := r.running[index]
+
+	switch event.Type {
+	case JobPod:
+		job.detail = event.Detail
+	case JobWrote:
+		job.bytes += int64(event.Bytes)
+	case JobFinished:
+		job.end = event.Time
+		job.err = event.Err
+		r.running = append(r.running[:index], r.running[index+1:]...)
+		r.finished = append(r.finished, job)
+		if r.tty {
+			r.draw()
+		} else {
+			fmt.Fprintf(r.w, "Job %s... %s (%s, %s)\n", job.name, job.status(), formatDuration(job.duration()), humanize.Bytes(uint64(job.bytes)))
+		}
+	}
+}
+
+// Close stops redrawing and prints the timing table of the finished jobs.
+func (r *Renderer) Close() {
+	if r.tty {
+		close(r.stop)
+		<-r.done
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tty {
+		r.draw()
+	}
+
+	// The slowest jobs come first, as they are the ones worth tuning
+	jobs := slices.Clone(r.finished)
+	slices.SortStableFunc(jobs, func(a, b *jobState) int {
+		return cmp.Compare(b.duration(), a.duration())
+	})
+
+	var total int64
+	tw := tabwriter.NewWriter(r.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nJOB\tSTATUS\tDURATION\tBYTES")
+	for _, job := range jobs {
+		status := "OK"
+		if job.err != nil {
+			status = "FAILED"
+		}
+		total += job.bytes
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", job.name, status, formatDuration(job.duration()), humanize.Bytes(uint64(job.bytes)))
+	}
+	fmt.Fprintf(tw, "%d job(s)\t\t\t%s\n", len(r.finished), humanize.Bytes(uint64(total)))
+	_ = tw.Flush()
+}
+
+func (r *Renderer) refresh() {
+	defer close(r.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.frame++
+			r.draw()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// draw erases the running jobs drawn last, prints the jobs finished since, then the running
+// jobs again. It must be called with the lock held.
+func (r *Renderer) draw() {
+	if r.lines > 0 {
+		fmt.Fprintf(r.w, "\x1b[%dA\x1b[J", r.lines)
+	}
+	for _, job := range r.finished[r.printed:] {
+		mark := "✓"
+		if job.err != nil {
+			mark = "✗"
+		}
+		r.printLine(fmt.Sprintf("%s %s %s %s %s", mark, job.name, formatDuration(job.duration()), humanize.Bytes(uint64(job.bytes)), job.status()))
+	}
+	r.printed = len(r.finished)
+
+	spinner := spinnerFrames[r.frame%len(spinnerFrames)]
+	for _, job := range r.running {
+		line := fmt.Sprintf("%s %s %s %s", spinner, job.name, formatDuration(job.duration()), humanize.Bytes(uint64(job.bytes)))
+		if job.detail != "" {
+			line += " " + job.detail
+		}
+		r.printLine(line)
+	}
+	r.lines = len(r.running)
+}
+
+// printLine prints a line cut to the width of the terminal, so that lines are never wrapped
+// and the running jobs can be erased by moving the cursor up.
+func (r *Renderer) printLine(line string) {
+	if runes := []rune(line); r.width > 1 && len(runes) >= r.width {
+		line = string(runes[:r.width-1])
+	}
+	fmt.Fprintln(r.w, line)
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(10 * time.Millisecond).String()
+}
@@ -0,0 +1,95 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runJobs sends the events of two jobs, the second one failing with an error spanning several lines.
+func runJobs(r *Renderer) {
+	start := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	r.Handle(Event{Type: JobStarted, Job: "pod-list", Time: start})
+	r.Handle(Event{Type: JobStarted, Job: "exec-nginx-t", Time: start})
+	r.Handle(Event{Type: JobPod, Job: "exec-nginx-t", Detail: "web/nginx-5c6d8"})
+	r.Handle(Event{Type: JobWrote, Job: "pod-list", Bytes: 1500})
+	r.Handle(Event{Type: JobWrote, Job: "pod-list", Bytes: 500})
+	r.Handle(Event{Type: JobFinished, Job: "pod-list", Time: start.Add(250 * time.Millisecond)})
+	r.Handle(Event{Type: JobWrote, Job: "unknown", Bytes: 100})
+	r.Handle(Event{
+		Type: JobFinished,
+		Job:  "exec-nginx-t",
+		Time: start.Add(1500 * time.Millisecond),
+		Err:  errors.New("command terminated with exit code 1:\nnginx: [emerg] unknown directive\n  in /etc/nginx/nginx.conf:12"),
+	})
+}
+
+func TestPlainRenderer(t *testing.T) {
+	var out bytes.Buffer
+	r := NewPlainRenderer(&out)
+	runJobs(r)
+	r.Close()
+
+	want := `Job pod-list... OK (250ms, 2.0 kB)
+Job exec-nginx-t... Error: command terminated with exit code 1: nginx: [emerg] unknown directive in /etc/nginx/nginx.conf:12 (1.5s, 0 B)
+
+JOB           STATUS  DURATION  BYTES
+exec-nginx-t  FAILED  1.5s      0 B
+pod-list      OK      250ms     2.0 kB
+2 job(s)                        2.0 kB
+`
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", out.String(), want)
+	}
+}
+
+func TestTTYRenderer(t *testing.T) {
+	var out bytes.Buffer
+	r := NewTTYRenderer(&out, 60)
+	runJobs(r)
+	r.Close()
+
+	// Every finished job is printed once, on a line cut to the width of the terminal
+	var finished []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if i := strings.LastIndex(line, "\x1b[J"); i >= 0 {
+			line = line[i+len("\x1b[J"):]
+		}
+		if len([]rune(line)) >= 60 {
+			t.Errorf("line %q is wider than the terminal", line)
+		}
+		if strings.HasPrefix(line, "✓ ") || strings.HasPrefix(line, "✗ ") {
+			finished = append(finished, line)
+		}
+	}
+	want := []string{
+		"✓ pod-list 250ms 2.0 kB OK",
+		"✗ exec-nginx-t 1.5s 0 B Error: command terminated with exit",
+	}
+	if strings.Join(finished, "\n") != strings.Join(want, "\n") {
+		t.Errorf("finished jobs:\n%s\nexpected:\n%s", strings.Join(finished, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out.String(), "exec-nginx-t  FAILED  1.5s") {
+		t.Errorf("timing table missing:\n%s", out.String())
+	}
+}
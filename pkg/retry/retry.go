@@ -0,0 +1,205 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// Policy sets how failed requests are retried: up to Retries times, waiting an exponential
+// backoff from InitialBackoff up to MaxBackoff, with jitter, or as long as the server asks.
+type Policy struct {
+	Retries        int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultPolicy = Policy{Retries: 3, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// Attempt describes a failed attempt, retried or not.
+type Attempt struct {
+	// Number is 1 for the first attempt
+	Number int
+	Err    error
+	// Wait is the delay before the next attempt, zero when there is none or the server asked for none
+	Wait time.Duration
+}
+
+// Backoff returns the delay after the given failed attempt: half of the exponential backoff,
+// plus a random part of the other half so that concurrent clients do not retry in step.
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// Do calls fn until it succeeds, returns an error that is not transient, or the retries are
+// exhausted. Each failed attempt is reported to onFailure.
+func Do(ctx context.Context, policy Policy, fn func() error, onFailure func(Attempt)) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		wait := policy.Backoff(attempt)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			wait = time.Duration(seconds) * time.Second
+		}
+		// A delay of zero asked by the server is retried at once
+		retry := attempt <= policy.Retries && Transient(err) && ctx.Err() == nil
+		if !retry {
+			wait = 0
+		}
+		if onFailure != nil {
+			onFailure(Attempt{Number: attempt, Err: err, Wait: wait})
+		}
+		if !retry {
+			return err
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// Transient tells whether an error is worth retrying: throttling, server errors, timeouts and
+// broken connections or streams are, while errors such as forbidden requests, missing
+// resources or commands exiting with an error status are not.
+func Transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		return false
+	}
+	var statusErr apierrors.APIStatus
+	if errors.As(err, &statusErr) {
+		return apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+			apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err)
+	}
+	return true
+}
+
+// TransientStatus tells whether an HTTP response status is worth retrying.
+func TransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// RetryAfter returns the delay asked by the Retry-After header of a response, in seconds or as a date.
+func RetryAfter(response *http.Response) (time.Duration, bool) {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// Transport retries the idempotent requests without body, GET and HEAD, on network errors and
+// on transient statuses, honoring Retry-After. Other requests, such as pod executions, are sent once.
+type Transport struct {
+	Next      http.RoundTripper
+	Policy    func() Policy
+	OnFailure func(request *http.Request, attempt Attempt)
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if (request.Method != http.MethodGet && request.Method != http.MethodHead) || request.Body != nil {
+		return t.Next.RoundTrip(request)
+	}
+
+	policy := t.Policy()
+	ctx := request.Context()
+	for attempt := 1; ; attempt++ {
+		response, err := t.Next.RoundTrip(request)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if !Transient(err) {
+				return response, err
+			}
+			wait = policy.Backoff(attempt)
+		case TransientStatus(response.StatusCode):
+			wait = policy.Backoff(attempt)
+			if retryAfter, ok := RetryAfter(response); ok {
+				wait = retryAfter
+			}
+			err = errors.New("server responded with " + response.Status)
+		default:
+			return response, nil
+		}
+
+		retry := attempt <= policy.Retries && ctx.Err() == nil
+		if !retry {
+			wait = 0
+		}
+		if t.OnFailure != nil {
+			t.OnFailure(request, Attempt{Number: attempt, Err: err, Wait: wait})
+		}
+		if !retry {
+			// The last response is returned for the client to report its status, without the
+			// Retry-After header that would make client-go retry the request again, up to 10 times
+			if response != nil {
+				response.Header.Del("Retry-After")
+				return response, nil
+			}
+			return nil, err
+		}
+		if response != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+			_ = response.Body.Close()
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
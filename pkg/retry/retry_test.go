@@ -0,0 +1,356 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// testPolicy retries quickly, so that the tests do not wait.
+var testPolicy = Policy{Retries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{Retries: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		backoff time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, test := range tests {
+		// The jitter draws the delay between half of the backoff and the backoff
+		var spread bool
+		first := policy.Backoff(test.attempt)
+		for range 200 {
+			wait := policy.Backoff(test.attempt)
+			if wait < test.backoff/2 || wait > test.backoff {
+				t.Fatalf("attempt %d waits %s, out of [%s, %s]", test.attempt, wait, test.backoff/2, test.backoff)
+			}
+			spread = spread || wait != first
+		}
+		if !spread {
+			t.Errorf("attempt %d always waits %s, without jitter", test.attempt, first)
+		}
+	}
+	if wait := (Policy{}).Backoff(1); wait != 0 {
+		t.Errorf("the zero policy waits %s", wait)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 1), true},
+		{"service unavailable", apierrors.NewServiceUnavailable("unavailable"), true},
+		{"internal error", apierrors.NewInternalError(errors.New("etcd")), true},
+		{"server timeout", apierrors.NewServerTimeout(pods, "list", 1), true},
+		{"stream reset", errors.New("stream error: stream ID 3; INTERNAL_ERROR"), true},
+		{"forbidden", apierrors.NewForbidden(pods, "nginx", errors.New("denied")), false},
+		{"not found", apierrors.NewNotFound(pods, "nginx"), false},
+		{"command failure", utilexec.CodeExitError{Err: errors.New("exit 1"), Code: 1}, false},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if Transient(test.err) != test.transient {
+				t.Errorf("transient is %t", !test.transient)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	unavailable := apierrors.NewServiceUnavailable("unavailable")
+	tests := []struct {
+		name     string
+		failures []error
+		wantErr  bool
+		calls    int
+	}{
+		{name: "success", calls: 1},
+		{name: "transient failures", failures: []error{unavailable, unavailable}, calls: 3},
+		{name: "retries exhausted", failures: []error{unavailable, unavailable, unavailable}, wantErr: true, calls: 3},
+		{name: "forbidden", failures: []error{apierrors.NewForbidden(pods, "nginx", errors.New("denied"))}, wantErr: true, calls: 1},
+		{name: "too many requests", failures: []error{apierrors.NewTooManyRequests("slow down", 0)}, calls: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			var attempts []Attempt
+			err := Do(context.Background(), testPolicy, func() error {
+				calls++
+				if calls <= len(test.failures) {
+					return test.failures[calls-1]
+				}
+				return nil
+			}, func(attempt Attempt) {
+				attempts = append(attempts, attempt)
+			})
+			if test.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if calls != test.calls {
+				t.Errorf("%d calls, expected %d", calls, test.calls)
+			}
+			if len(attempts) != min(len(test.failures), test.calls) {
+				t.Fatalf("%d failed attempts reported", len(attempts))
+			}
+			for i, attempt := range attempts {
+				if attempt.Number != i+1 {
+					t.Errorf("attempt %d numbered %d", i+1, attempt.Number)
+				}
+			}
+			if test.wantErr && attempts[len(attempts)-1].Wait != 0 {
+				t.Errorf("the last attempt waits %s", attempts[len(attempts)-1].Wait)
+			}
+		})
+	}
+}
+
+func TestDoHonorsSuggestedDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var waits []time.Duration
+	err := Do(ctx, testPolicy, func() error {
+		return apierrors.NewTooManyRequests("slow down", 7)
+	}, func(attempt Attempt) {
+		waits = append(waits, attempt.Wait)
+		// Cancelling stops the wait, instead of sleeping 7s
+		cancel()
+	})
+	if !apierrors.IsTooManyRequests(err) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 7*time.Second {
+		t.Errorf("unexpected waits %v", waits)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{}}
+		if test.value != "" {
+			response.Header.Set("Retry-After", test.value)
+		}
+		wait, ok := RetryAfter(response)
+		if wait != test.wait || ok != test.ok {
+			t.Errorf("Retry-After %q: got %s %t, expected %s %t", test.value, wait, ok, test.wait, test.ok)
+		}
+	}
+	response := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}}
+	if wait, ok := RetryAfter(response); !ok || wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("Retry-After in an hour: got %s %t", wait, ok)
+	}
+}
+
+// statusServer answers with the given statuses and Retry-After values, then with 200.
+func statusServer(t *testing.T, statuses []int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestTransport(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		statuses   []int
+		retryAfter string
+		wantStatus int
+		requests   int
+	}{
+		{name: "success", method: http.MethodGet, wantStatus: http.StatusOK, requests: 1},
+		{name: "service unavailable", method: http.MethodGet, statuses: []int{503, 503}, wantStatus: http.StatusOK, requests: 3},
+		{name: "too many requests", method: http.MethodGet, statuses: []int{429}, retryAfter: "0", wantStatus: http.StatusOK, requests: 2},
+		{name: "retry after a date", method: http.MethodHead, statuses: []int{429}, retryAfter: past, wantStatus: http.StatusOK, requests: 2},
+		{name: "retries exhausted", method: http.MethodGet, statuses: []int{503, 503, 503}, wantStatus: http.StatusServiceUnavailable, requests: 3},
+		{name: "not transient", method: http.MethodGet, statuses: []int{404}, wantStatus: http.StatusNotFound, requests: 1},
+		{name: "not idempotent", method: http.MethodPost, body: "{}", statuses: []int{503}, wantStatus: http.StatusServiceUnavailable, requests: 1},
+		{name: "exec upgrade", method: http.MethodGet, body: "{}", statuses: []int{503}, wantStatus: http.StatusServiceUnavailable, requests: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := statusServer(t, test.statuses, test.retryAfter)
+			var attempts []Attempt
+			client := &http.Client{Transport: &Transport{
+				Next:   http.DefaultTransport,
+				Policy: func() Policy { return testPolicy },
+				OnFailure: func(request *http.Request, attempt Attempt) {
+					attempts = append(attempts, attempt)
+				},
+			}}
+
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+			request, err := http.NewRequest(test.method, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = response.Body.Close()
+			if response.StatusCode != test.wantStatus {
+				t.Errorf("status %d, expected %d", response.StatusCode, test.wantStatus)
+			}
+			if int(requests.Load()) != test.requests {
+				t.Errorf("%d requests, expected %d", requests.Load(), test.requests)
+			}
+			if len(attempts) != test.requests-1 && test.wantStatus == http.StatusOK {
+				t.Errorf("%d failed attempts reported, expected %d", len(attempts), test.requests-1)
+			}
+		})
+	}
+}
+
+func TestTransportRetryAfter(t *testing.T) {
+	server, requests := statusServer(t, []int{503}, "5")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var waits []time.Duration
+	client := &http.Client{Transport: &Transport{
+		Next:   http.DefaultTransport,
+		Policy: func() Policy { return testPolicy },
+		OnFailure: func(request *http.Request, attempt Attempt) {
+			waits = append(waits, attempt.Wait)
+			// Cancelling stops the wait, instead of sleeping 5s
+			cancel()
+		},
+	}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Do(request); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 5*time.Second || requests.Load() != 1 {
+		t.Errorf("unexpected waits %v after %d requests", waits, requests.Load())
+	}
+}
+
+func TestTransportClientRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		isError func(error) bool
+	}{
+		{name: "too many requests", status: http.StatusTooManyRequests, isError: apierrors.IsTooManyRequests},
+		{name: "service unavailable", status: http.StatusServiceUnavailable, isError: apierrors.IsServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statuses := make([]int, 100)
+			for i := range statuses {
+				statuses[i] = test.status
+			}
+			server, requests := statusServer(t, statuses, "0")
+			config := &rest.Config{Host: server.URL, QPS: -1}
+			config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+				return &Transport{Next: next, Policy: func() Policy { return testPolicy }}
+			})
+			clientSet, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The client does not retry the requests whose retries are exhausted
+			_, err = clientSet.CoreV1().Namespaces().Get(context.Background(), "default", metav1.GetOptions{})
+			if !test.isError(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+			if int(requests.Load()) != testPolicy.Retries+1 {
+				t.Errorf("%d requests, expected %d", requests.Load(), testPolicy.Retries+1)
+			}
+		})
+	}
+}
+
+func TestTransportNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	var attempts []Attempt
+	client := &http.Client{Transport: &Transport{
+		Next:   http.DefaultTransport,
+		Policy: func() Policy { return testPolicy },
+		OnFailure: func(request *http.Request, attempt Attempt) {
+			attempts = append(attempts, attempt)
+		},
+	}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(attempts) != testPolicy.Retries+1 {
+		t.Errorf("%d failed attempts, expected %d", len(attempts), testPolicy.Retries+1)
+	}
+}
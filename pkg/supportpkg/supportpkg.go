@@ -0,0 +1,429 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+// Package supportpkg collects support packages from Go programs, as the nginx-supportpkg command does.
+package supportpkg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/jobs"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/retry"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/throttle"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultConcurrency is the number of jobs run in parallel when Options.Concurrency is not set.
+const DefaultConcurrency = 4
+
+// ErrPreflight ends a collection before any package is written when the cluster or one of the
+// namespaces cannot be reached, for callers to tell it apart with errors.Is.
+var ErrPreflight = errors.New("preflight check failed")
+
+// Options configure a collection. Only Namespaces and Products are required.
+type Options struct {
+	// RestConfig connects to the cluster, the kubeconfig of $KUBECONFIG or ~/.kube/config is used when nil
+	RestConfig *rest.Config
+	Namespaces []string
+	// Products are collected in turn, each into its own package
+	Products []string
+	// IncludeJobs and ExcludeJobs filter the jobs by name, see jobs.Filter
+	IncludeJobs []string
+	ExcludeJobs []string
+	// Collectors are external collector executables, run after the jobs of each product as
+	// collector-<name> jobs, see jobs.ExternalCollectorJob
+	Collectors []string
+	// JobTimeouts override the timeout of jobs by name, after the timeouts are multiplied by TimeoutScale, 1 when 0
+	JobTimeouts  map[string]time.Duration
+	TimeoutScale float64
+	// Concurrency is the number of jobs run in parallel, DefaultConcurrency when 0
+	Concurrency int
+	// Output sets where and how the package is written, by default as a tar.gz named after
+	// data_collector.DefaultNameTemplate in the working directory
+	Output data_collector.OutputOptions
+	// Writer, when set, receives the package as it is collected instead of a file, for a single product only
+	Writer         io.Writer
+	AllNamespaces  bool
+	AllCRDVersions bool
+	// Retry is the policy for failed API requests and pod executions, retry.DefaultPolicy when nil
+	Retry *retry.Policy
+	// QPS and Burst limit the rate of requests to the API server, throttle.DefaultQPS and
+	// throttle.DefaultBurst when 0; a negative QPS disables rate limiting
+	QPS   float64
+	Burst int
+	// WorkDir stages the files and a checkpoint, kept until every job is collected, for a single product only
+	WorkDir string
+	// Resume runs only the jobs not yet collected by the interrupted collection in WorkDir
+	Resume bool
+	// Progress receives the events of the jobs, it is called concurrently when jobs run in parallel
+	Progress func(progress.Event)
+	// Log, when set, receives the records of supportpkg.log as text
+	Log io.Writer
+	// Args are recorded in supportpkg.log, such as the command line the collection was started with
+	Args []string
+	// NewCollector creates the collector of each product, to use other clients than those of
+	// RestConfig such as fakes in tests
+	NewCollector func(workDir string, namespaces ...string) (*data_collector.DataCollector, error)
+}
+
+// JobStatus tells how a job of a package ended.
+type JobStatus string
+
+const (
+	JobOK     JobStatus = "ok"
+	JobFailed JobStatus = "failed"
+	// JobSkipped is the status of the jobs not run as the context was done
+	JobSkipped JobStatus = "skipped"
+	// JobResumed is the status of the jobs collected by the interrupted collection of the work directory
+	JobResumed JobStatus = "resumed"
+)
+
+// JobResult is the outcome of a job, with Err set for failed jobs.
+type JobResult struct {
+	Name     string
+	Status   JobStatus
+	Duration time.Duration
+	Err      error
+}
+
+// Package is the support package of a product.
+type Package struct {
+	Product string
+	// Path is the path of the archive, empty when it is written to Options.Writer
+	Path string
+	// Jobs are the resumed jobs followed by the jobs run, in list order
+	Jobs []JobResult
+	// StagingDir holds the collected files when they are kept: in the work directory until every
+	// job is collected, or when the package could not be written
+	StagingDir string
+	// ProductPods are the pods of the product found in the namespaces, as namespace/name. When
+	// there are none the package is still written, without what the jobs collect from them.
+	ProductPods []string
+}
+
+// Count returns the number of jobs with the status.
+func (p Package) Count(status JobStatus) int {
+	count := 0
+	for _, job := range p.Jobs {
+		if job.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Result holds the packages of a collection, one per product, in the order of Options.Products.
+type Result struct {
+	Packages []Package
+}
+
+// Collect writes a support package for each product of the options. Once ctx is done, running
+// jobs are cancelled and the others skipped before the package is written. Failed jobs do not
+// make Collect fail, they are reported in the result; an error is returned when a package cannot
+// be written, along with the packages written before it.
+func Collect(ctx context.Context, options Options) (Result, error) {
+	var result Result
+	if len(options.Products) == 0 {
+		return result, errors.New("no product to collect")
+	}
+	if len(options.Products) > 1 && (options.Writer != nil || options.WorkDir != "" || options.Output.Path != "") {
+		return result, errors.New("a single product can be collected to a writer, an output path or a work directory")
+	}
+	if options.Resume && options.WorkDir == "" {
+		return result, errors.New("resuming requires the work directory of the interrupted collection")
+	}
+
+	// Every job list is checked before collecting anything
+	jobLists := make([][]jobs.Job, len(options.Products))
+	for i, product := range options.Products {
+		var err error
+		if jobLists[i], err = productJobs(product, options); err != nil {
+			return result, err
+		}
+	}
+
+	for i, product := range options.Products {
+		pkg, err := collect(ctx, product, jobLists[i], options)
+		if pkg != nil {
+			result.Packages = append(result.Packages, *pkg)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// productJobs returns the jobs of the product, with their timeouts and filters applied.
+func productJobs(product string, options Options) ([]jobs.Job, error) {
+	jobList, err := jobs.ProductJobList(product)
+	if err != nil {
+		return nil, err
+	}
+	for _, executable := range options.Collectors {
+		job := jobs.ExternalCollectorJob(executable)
+		if slices.ContainsFunc(jobList, func(other jobs.Job) bool { return other.Name == job.Name }) {
+			return nil, fmt.Errorf("external collector %s is named like another job, %s", executable, job.Name)
+		}
+		jobList = append(jobList, job)
+	}
+	timeoutScale := options.TimeoutScale
+	if timeoutScale == 0 {
+		timeoutScale = 1
+	}
+	if jobList, err = jobs.ApplyTimeouts(jobList, timeoutScale, options.JobTimeouts); err != nil {
+		return nil, err
+	}
+	return jobs.Filter(jobList, options.IncludeJobs, options.ExcludeJobs)
+}
+
+// newCollector creates the collector of a product and applies the options to it.
+func newCollector(options Options) (*data_collector.DataCollector, error) {
+	var collector *data_collector.DataCollector
+	var err error
+	switch {
+	case options.NewCollector != nil:
+		collector, err = options.NewCollector(options.WorkDir, options.Namespaces...)
+	case options.RestConfig != nil:
+		collector, err = data_collector.NewDataCollectorForConfig(options.RestConfig, options.WorkDir, options.Namespaces...)
+	default:
+		collector, err = data_collector.NewDataCollectorInDir(options.WorkDir, options.Namespaces...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	collector.Output = options.Output
+	if collector.Output.Dir == "" {
+		collector.Output.Dir = "."
+	}
+	if collector.Output.NameTemplate == "" {
+		collector.Output.NameTemplate = data_collector.DefaultNameTemplate
+	}
+	if collector.Output.Format == "" {
+		collector.Output.Format = archive.TarGz
+	}
+	if options.Writer != nil {
+		collector.Output.Path = data_collector.StreamOutput
+	}
+	if options.Retry != nil {
+		collector.Retry = *options.Retry
+	}
+	qps, burst := options.QPS, options.Burst
+	if qps == 0 {
+		qps = throttle.DefaultQPS
+	}
+	if burst == 0 {
+		burst = throttle.DefaultBurst
+	}
+	collector.Throttle.SetLimit(qps, burst)
+	if options.Log != nil {
+		collector.MirrorLogs(options.Log)
+	}
+	collector.AllCRDVersions = options.AllCRDVersions
+	collector.AllNamespaces = options.AllNamespaces
+	collector.Progress = options.Progress
+	return collector, nil
+}
+
+// collect writes the package of a product. It returns a nil package when the collection fails
+// before any job is run.
+func collect(ctx context.Context, product string, jobList []jobs.Job, options Options) (*Package, error) {
+	collector, err := newCollector(options)
+	if err != nil {
+		return nil, preflightError{ErrPreflight, fmt.Errorf("unable to start data collector: %w", err)}
+	}
+	wrappingUp := false
+	defer func() {
+		if !wrappingUp {
+			collector.Discard()
+		}
+	}()
+	collector.Logger.Info("Starting kubectl-nginx-supportpkg", "version", version.Version, "build", version.Build)
+	if options.Args != nil {
+		collector.Logger.Info("Input args", "args", options.Args)
+	}
+
+	// The jobs completed by an earlier run in the work directory are not run again when resuming
+	pkg := &Package{Product: product}
+	var checkpoint *data_collector.Checkpoint
+	if options.WorkDir != "" {
+		checkpoint, err = collector.LoadCheckpoint()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the checkpoint of %s: %w", options.WorkDir, err)
+		}
+		switch {
+		case options.Resume && checkpoint == nil:
+			return nil, fmt.Errorf("no collection to resume in %s", options.WorkDir)
+		case options.Resume && (checkpoint.Product != product || !sameNamespaces(checkpoint.Namespaces, options.Namespaces)):
+			return nil, fmt.Errorf("the collection in %s is for product %s in namespaces %s", options.WorkDir, checkpoint.Product, strings.Join(checkpoint.Namespaces, ", "))
+		case options.Resume:
+			collector.StartTime = checkpoint.Started
+			var resumedJobs []string
+			jobList = slices.DeleteFunc(jobList, func(job jobs.Job) bool {
+				if slices.Contains(checkpoint.Completed, job.Name) {
+					resumedJobs = append(resumedJobs, job.Name)
+					pkg.Jobs = append(pkg.Jobs, JobResult{Name: job.Name, Status: JobResumed})
+					return true
+				}
+				return false
+			})
+			collector.Logger.Info("Resuming collection", "workDir", options.WorkDir, "completed", resumedJobs)
+		case checkpoint != nil:
+			return nil, fmt.Errorf("%s holds an interrupted collection, resume it or use another work directory", options.WorkDir)
+		default:
+			checkpoint = &data_collector.Checkpoint{Product: product, Namespaces: options.Namespaces, Started: collector.StartTime}
+		}
+	}
+
+	if _, _, err = collector.OutputPath(product); err != nil {
+		return nil, err
+	}
+
+	if err = collector.AllNamespacesExist(ctx); err != nil {
+		return nil, preflightError{ErrPreflight, err}
+	}
+	productPods, err := jobs.FindProductPods(collector, product, ctx)
+	if err != nil {
+		return nil, preflightError{ErrPreflight, err}
+	}
+	// A product whose pods are missing or renamed is when a package is needed most, so the
+	// collection goes on without them
+	if len(productPods) == 0 {
+		collector.Logger.Warn("No product pod found, collecting the namespaces only", "product", product, "namespaces", options.Namespaces)
+	} else {
+		collector.Logger.Info("Found product pods", "product", product, "pods", productPods)
+	}
+	collector.ProductPods = productPods
+	pkg.ProductPods = productPods
+
+	// The checkpoint is written once the collection can start, so that a failed preflight leaves
+	// the work directory as it was
+	if checkpoint != nil {
+		if err = collector.StartCheckpoint(*checkpoint); err != nil {
+			return nil, fmt.Errorf("unable to write the checkpoint in %s: %w", options.WorkDir, err)
+		}
+	}
+
+	var streamOut *bufio.Writer
+	if options.Writer != nil {
+		streamOut = bufio.NewWriter(options.Writer)
+		if err = collector.StartStream(product, streamOut); err != nil {
+			return nil, fmt.Errorf("unable to start streaming: %w", err)
+		}
+	}
+
+	// Jobs run in parallel, at most Concurrency at a time, and are reported in list order.
+	// Once ctx is done, running jobs are cancelled and the others are skipped.
+	jobErrors := make([]error, len(jobList))
+	jobDurations := make([]time.Duration, len(jobList))
+	jobSkipped := make([]bool, len(jobList))
+	concurrency := options.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultConcurrency
+	}
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, job := range jobList {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			jobSkipped[i] = true
+			<-slots
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			jobErrors[i] = job.Collect(collector, ctx)
+			jobDurations[i] = time.Since(start)
+			if jobErrors[i] == nil {
+				if err := collector.MarkCompleted(job.Name); err != nil {
+					collector.Logger.Error("Unable to update the checkpoint", "job", job.Name, "error", err)
+				}
+			}
+			<-slots
+		}()
+	}
+	wg.Wait()
+
+	for i, job := range jobList {
+		jobResult := JobResult{Name: job.Name, Status: JobOK, Duration: jobDurations[i], Err: jobErrors[i]}
+		switch {
+		case jobSkipped[i]:
+			collector.Logger.Warn("Job skipped", "job", job.Name, "reason", context.Cause(ctx))
+			jobResult.Status = JobSkipped
+		case jobErrors[i] != nil:
+			jobResult.Status = JobFailed
+		}
+		pkg.Jobs = append(pkg.Jobs, jobResult)
+	}
+
+	// The staged files and the checkpoint stay in the work directory until every job is collected
+	collector.KeepFiles = options.WorkDir != "" && (pkg.Count(JobFailed) > 0 || pkg.Count(JobSkipped) > 0)
+	if collector.KeepFiles {
+		pkg.StagingDir = collector.BaseDir
+	}
+
+	wrappingUp = true
+	pkg.Path, err = collector.WrapUp(product)
+	if err == nil && streamOut != nil {
+		err = streamOut.Flush()
+	}
+	if err != nil {
+		if options.Writer == nil {
+			pkg.StagingDir = collector.BaseDir
+		}
+		return pkg, fmt.Errorf("error when wrapping up: %w", err)
+	}
+	if options.Writer != nil {
+		pkg.Path = ""
+	}
+	return pkg, nil
+}
+
+// preflightError is returned when the collection of a product cannot start, it matches kind with errors.Is.
+type preflightError struct {
+	kind error
+	err  error
+}
+
+func (e preflightError) Error() string {
+	return e.err.Error()
+}
+
+func (e preflightError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// sameNamespaces tells whether two lists hold the same namespaces, in any order.
+func sameNamespaces(a, b []string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
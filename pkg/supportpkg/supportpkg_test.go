@@ -0,0 +1,504 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package supportpkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/archive"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/data_collector"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/jobs"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/manifest"
+	"github.com/nginxinc/nginx-k8s-supportpkg/pkg/progress"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// fakeCollector returns a NewCollector option serving the objects from a fake core client, the
+// jobs needing other clients fail.
+func fakeCollector(t *testing.T, objects ...runtime.Object) func(string, ...string) (*data_collector.DataCollector, error) {
+	return func(workDir string, namespaces ...string) (*data_collector.DataCollector, error) {
+		dc, err := data_collector.NewDataCollectorForConfig(&rest.Config{Host: "https://127.0.0.1:1"}, workDir, namespaces...)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { _ = os.RemoveAll(dc.BaseDir) })
+		dc.K8sCoreClientSet = fake.NewClientset(objects...)
+		return dc, nil
+	}
+}
+
+func namespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func pod(namespace string, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx"}}},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	options := Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list", "configmap-list"},
+		Output:       data_collector.OutputOptions{Path: filepath.Join(dir, "ngx.tar.gz"), Format: archive.TarGz},
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "nginx-5c6d8")),
+	}
+
+	result, err := Collect(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Packages) != 1 {
+		t.Fatalf("%d packages, expected 1", len(result.Packages))
+	}
+	pkg := result.Packages[0]
+	if pkg.Product != "ngx" || pkg.Path != options.Output.Path || pkg.StagingDir != "" || !slices.Equal(pkg.ProductPods, []string{"web/nginx-5c6d8"}) {
+		t.Errorf("unexpected package %+v", pkg)
+	}
+	var jobs []string
+	for _, job := range pkg.Jobs {
+		if job.Status != JobOK || job.Err != nil {
+			t.Errorf("job %s: %s %v", job.Name, job.Status, job.Err)
+		}
+		jobs = append(jobs, job.Name)
+	}
+	if !slices.Equal(jobs, []string{"pod-list", "configmap-list"}) {
+		t.Errorf("unexpected jobs %v", jobs)
+	}
+
+	if files := packageFiles(t, pkg.Path); !slices.Contains(files, "resources/web/pods.json") {
+		t.Errorf("pods.json not in the package: %v", files)
+	}
+}
+
+func TestCollectWithoutProduct(t *testing.T) {
+	options := Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list", "exec-nginx-t"},
+		Output:       data_collector.OutputOptions{Path: filepath.Join(t.TempDir(), "ngx.tar.gz"), Format: archive.TarGz},
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "redis-8f7d6")),
+	}
+
+	result, err := Collect(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := result.Packages[0]
+	if pkg.Path != options.Output.Path || len(pkg.ProductPods) != 0 {
+		t.Errorf("unexpected package %+v", pkg)
+	}
+	want := map[string]JobStatus{"pod-list": JobOK, "exec-nginx-t": JobOK}
+	if statuses := jobStatuses(pkg); !maps.Equal(statuses, want) {
+		t.Errorf("statuses %v, expected %v", statuses, want)
+	}
+	files := packageFiles(t, pkg.Path)
+	if !slices.Contains(files, "resources/web/pods.json") {
+		t.Errorf("pods.json not in the package: %v", files)
+	}
+	if slices.ContainsFunc(files, func(name string) bool { return strings.HasPrefix(name, "exec/") }) {
+		t.Errorf("commands run without product pods: %v", files)
+	}
+}
+
+func TestCollectToWriter(t *testing.T) {
+	var out bytes.Buffer
+	result, err := Collect(context.Background(), Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list"},
+		Writer:       &out,
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "nginx-5c6d8")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Packages[0].Path != "" {
+		t.Errorf("unexpected path %q", result.Packages[0].Path)
+	}
+	if out.Len() == 0 {
+		t.Error("nothing written to the writer")
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		objects []runtime.Object
+		want    error
+		// message is a part of the error
+		message string
+	}{
+		{
+			name:    "unknown product",
+			options: Options{Namespaces: []string{"web"}, Products: []string{"nginx-plus"}},
+		},
+		{
+			name:    "invalid job pattern",
+			options: Options{Namespaces: []string{"web"}, Products: []string{"ngx"}, IncludeJobs: []string{"["}},
+		},
+		{
+			name:    "several products to a writer",
+			options: Options{Namespaces: []string{"web"}, Products: []string{"nic", "ngx"}, Writer: &bytes.Buffer{}},
+		},
+		{
+			name:    "missing namespace",
+			options: Options{Namespaces: []string{"web", "api"}, Products: []string{"ngx"}},
+			objects: []runtime.Object{namespace("web"), pod("web", "nginx-5c6d8")},
+			want:    ErrPreflight,
+			message: `namespace api: namespaces "api" not found`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.NewCollector = fakeCollector(t, test.objects...)
+			test.options.Output.Dir = t.TempDir()
+			result, err := Collect(context.Background(), test.options)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("error %q is not %q", err, test.want)
+			}
+			if !strings.Contains(err.Error(), test.message) {
+				t.Errorf("error %q does not contain %q", err, test.message)
+			}
+			if len(result.Packages) != 0 {
+				t.Errorf("unexpected packages %+v", result.Packages)
+			}
+		})
+	}
+}
+
+// packageFiles returns the files of the package at path, relative to its top directory.
+func packageFiles(t *testing.T, path string) []string {
+	t.Helper()
+	var files []string
+	err := archive.Walk(path, func(name string, _ io.Reader) error {
+		_, name, _ = strings.Cut(name, "/")
+		files = append(files, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func jobStatuses(pkg Package) map[string]JobStatus {
+	statuses := make(map[string]JobStatus)
+	for _, job := range pkg.Jobs {
+		statuses[job.Name] = job.Status
+	}
+	return statuses
+}
+
+func readCheckpoint(t *testing.T, workDir string) data_collector.Checkpoint {
+	t.Helper()
+	checkpointBytes, err := os.ReadFile(filepath.Join(workDir, data_collector.CheckpointFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint data_collector.Checkpoint
+	if err = json.Unmarshal(checkpointBytes, &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	return checkpoint
+}
+
+func TestCollectResume(t *testing.T) {
+	workDir := t.TempDir()
+	options := Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list", "configmap-list", "service-list"},
+		Concurrency:  1,
+		WorkDir:      workDir,
+		Output:       data_collector.OutputOptions{Path: filepath.Join(t.TempDir(), "ngx.tar.gz"), Format: archive.TarGz},
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "nginx-5c6d8")),
+	}
+
+	// The first collection is interrupted once pod-list is collected
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := options
+	interrupted.Progress = func(event progress.Event) {
+		if event.Type == progress.JobFinished && event.Job == "pod-list" {
+			cancel()
+		}
+	}
+	result, err := Collect(ctx, interrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := result.Packages[0]
+	want := map[string]JobStatus{"pod-list": JobOK, "configmap-list": JobSkipped, "service-list": JobSkipped}
+	if statuses := jobStatuses(pkg); !maps.Equal(statuses, want) {
+		t.Errorf("interrupted collection: statuses %v, expected %v", statuses, want)
+	}
+	if pkg.StagingDir != filepath.Join(workDir, "files") {
+		t.Errorf("interrupted collection: staging directory %q", pkg.StagingDir)
+	}
+	if checkpoint := readCheckpoint(t, workDir); !slices.Equal(checkpoint.Completed, []string{"pod-list"}) {
+		t.Errorf("interrupted collection: completed jobs %v", checkpoint.Completed)
+	}
+	if _, err = os.Stat(filepath.Join(pkg.StagingDir, "resources", "web", "pods.json")); err != nil {
+		t.Errorf("interrupted collection: pods.json not staged: %v", err)
+	}
+
+	// Resuming runs the other jobs only and writes the full package
+	options.Resume = true
+	result, err = Collect(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg = result.Packages[0]
+	want = map[string]JobStatus{"pod-list": JobResumed, "configmap-list": JobOK, "service-list": JobOK}
+	if statuses := jobStatuses(pkg); !maps.Equal(statuses, want) {
+		t.Errorf("resumed collection: statuses %v, expected %v", statuses, want)
+	}
+	if pkg.StagingDir != "" {
+		t.Errorf("resumed collection: staging directory %q", pkg.StagingDir)
+	}
+	files := packageFiles(t, pkg.Path)
+	for _, name := range []string{"pods.json", "configmaps.json", "services.json"} {
+		if !slices.Contains(files, "resources/web/"+name) {
+			t.Errorf("resumed collection: %s not in the package: %v", name, files)
+		}
+	}
+	for _, name := range []string{data_collector.CheckpointFileName, "files"} {
+		if _, err = os.Stat(filepath.Join(workDir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("resumed collection: %s left in the work directory: %v", name, err)
+		}
+	}
+}
+
+func TestCollectKeepsFailedCollection(t *testing.T) {
+	workDir := t.TempDir()
+	result, err := Collect(context.Background(), Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list", "exec-nginx-t"},
+		WorkDir:      workDir,
+		Output:       data_collector.OutputOptions{Dir: t.TempDir()},
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "nginx-5c6d8")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := result.Packages[0]
+	want := map[string]JobStatus{"pod-list": JobOK, "exec-nginx-t": JobFailed}
+	if statuses := jobStatuses(pkg); !maps.Equal(statuses, want) {
+		t.Errorf("statuses %v, expected %v", statuses, want)
+	}
+	if pkg.StagingDir != filepath.Join(workDir, "files") {
+		t.Errorf("staging directory %q", pkg.StagingDir)
+	}
+	if checkpoint := readCheckpoint(t, workDir); !slices.Equal(checkpoint.Completed, []string{"pod-list"}) {
+		t.Errorf("completed jobs %v", checkpoint.Completed)
+	}
+}
+
+func TestCollectResumeErrors(t *testing.T) {
+	interrupted := data_collector.Checkpoint{
+		Product:    "ngx",
+		Namespaces: []string{"web"},
+		Started:    time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+		Completed:  []string{"pod-list"},
+	}
+	tests := []struct {
+		name       string
+		checkpoint *data_collector.Checkpoint
+		options    Options
+		objects    []runtime.Object
+		want       string
+		wantKind   error
+	}{
+		{
+			name:    "nothing to resume",
+			options: Options{Namespaces: []string{"web"}, Products: []string{"ngx"}, Resume: true},
+			objects: []runtime.Object{namespace("web"), pod("web", "nginx-5c6d8")},
+			want:    "no collection to resume",
+		},
+		{
+			name:       "interrupted collection not resumed",
+			checkpoint: &interrupted,
+			options:    Options{Namespaces: []string{"web"}, Products: []string{"ngx"}},
+			objects:    []runtime.Object{namespace("web"), pod("web", "nginx-5c6d8")},
+			want:       "holds an interrupted collection",
+		},
+		{
+			name:       "other product",
+			checkpoint: &interrupted,
+			options:    Options{Namespaces: []string{"web"}, Products: []string{"nic"}, Resume: true},
+			objects:    []runtime.Object{namespace("web"), pod("web", "nginx-ingress-7b9f4")},
+			want:       "is for product ngx in namespaces web",
+		},
+		{
+			name:       "other namespaces",
+			checkpoint: &interrupted,
+			options:    Options{Namespaces: []string{"web", "api"}, Products: []string{"ngx"}, Resume: true},
+			objects:    []runtime.Object{namespace("web"), namespace("api"), pod("web", "nginx-5c6d8")},
+			want:       "is for product ngx in namespaces web",
+		},
+		{
+			name:       "preflight failure when resuming",
+			checkpoint: &interrupted,
+			options:    Options{Namespaces: []string{"web"}, Products: []string{"ngx"}, Resume: true},
+			wantKind:   ErrPreflight,
+		},
+		{
+			name:     "preflight failure of a new collection",
+			options:  Options{Namespaces: []string{"web"}, Products: []string{"ngx"}},
+			wantKind: ErrPreflight,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			stagedFile := filepath.Join(workDir, "files", "resources", "web", "pods.json")
+			var checkpointBytes []byte
+			if test.checkpoint != nil {
+				var err error
+				if checkpointBytes, err = json.Marshal(test.checkpoint); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(filepath.Join(workDir, data_collector.CheckpointFileName), checkpointBytes, 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.MkdirAll(filepath.Dir(stagedFile), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(stagedFile, []byte("{}"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			test.options.WorkDir = workDir
+			test.options.Output.Dir = t.TempDir()
+			test.options.NewCollector = fakeCollector(t, test.objects...)
+			result, err := Collect(context.Background(), test.options)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.want != "" && !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %q does not contain %q", err, test.want)
+			}
+			if test.wantKind != nil && !errors.Is(err, test.wantKind) {
+				t.Errorf("error %q is not %q", err, test.wantKind)
+			}
+			if len(result.Packages) != 0 {
+				t.Errorf("unexpected packages %+v", result.Packages)
+			}
+
+			// The work directory is left as it was
+			gotBytes, err := os.ReadFile(filepath.Join(workDir, data_collector.CheckpointFileName))
+			switch {
+			case test.checkpoint == nil && !errors.Is(err, fs.ErrNotExist):
+				t.Errorf("checkpoint written: %v", err)
+			case test.checkpoint != nil && !bytes.Equal(gotBytes, checkpointBytes):
+				t.Errorf("checkpoint changed: %s, %v", gotBytes, err)
+			}
+			if _, err = os.Stat(stagedFile); test.checkpoint != nil && err != nil {
+				t.Errorf("staged file removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectRecordsCollectorRuns(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("the collectors of the test are shell scripts")
+	}
+	dir := t.TempDir()
+	var collectors []string
+	for name, script := range map[string]string{
+		"failing": "echo 'sidecar not found' >&2\nexit 3",
+		"slow":    "exec sleep 5",
+	} {
+		path := filepath.Join(dir, jobs.ExternalCollectorPrefix+name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		collectors = append(collectors, path)
+	}
+	slices.Sort(collectors)
+
+	result, err := Collect(context.Background(), Options{
+		Namespaces:   []string{"web"},
+		Products:     []string{"ngx"},
+		IncludeJobs:  []string{"pod-list", "collector-*"},
+		Collectors:   collectors,
+		JobTimeouts:  map[string]time.Duration{"collector-slow": 200 * time.Millisecond},
+		Output:       data_collector.OutputOptions{Path: filepath.Join(dir, "ngx.tar.gz"), Format: archive.TarGz},
+		NewCollector: fakeCollector(t, namespace("web"), pod("web", "nginx-5c6d8")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := result.Packages[0]
+	want := map[string]JobStatus{"pod-list": JobOK, "collector-failing": JobFailed, "collector-slow": JobFailed}
+	if statuses := jobStatuses(pkg); !maps.Equal(statuses, want) {
+		t.Errorf("statuses %v, expected %v", statuses, want)
+	}
+
+	// The runs are in the manifest, even that of the collector which timed out
+	var m manifest.Manifest
+	err = archive.Walk(pkg.Path, func(name string, r io.Reader) error {
+		if strings.HasSuffix(name, "/"+manifest.FileName) {
+			return json.NewDecoder(r).Decode(&m)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := make(map[string]manifest.CollectorRun)
+	for _, run := range m.Collectors {
+		runs[run.Name] = run
+	}
+	if run, ok := runs["failing"]; !ok || run.ExitCode != 3 || !strings.Contains(run.Output, "sidecar not found") || run.Error == "" {
+		t.Errorf("unexpected run of the failing collector %+v", run)
+	}
+	if run, ok := runs["slow"]; !ok || run.ExitCode != -1 || !strings.Contains(run.Error, context.DeadlineExceeded.Error()) {
+		t.Errorf("unexpected run of the slow collector %+v", run)
+	}
+}
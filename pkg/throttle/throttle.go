@@ -0,0 +1,150 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultQPS   = 5
+	DefaultBurst = 10
+
+	// After a 429 response the rate is halved, down to minimumShare of the configured rate.
+	// The 429 responses of the following throttleInterval are part of the same rejection wave,
+	// answering requests sent before the rate was lowered, and do not halve it again. The rate
+	// then grows back by recoveryShare of the configured rate after each recoveryInterval
+	// without throttling.
+	minimumShare     = 0.1
+	throttleInterval = 2 * time.Second
+	recoveryShare    = 0.1
+	recoveryInterval = 5 * time.Second
+)
+
+// Limiter limits the rate of the requests to the API server, shared by all clients. It slows down
+// when the API server throttles requests with 429 responses, and speeds up again to the configured
+// rate while requests succeed.
+type Limiter struct {
+	mu        sync.Mutex
+	limiter   *rate.Limiter
+	qps       float64
+	current   float64
+	changed   time.Time
+	throttled time.Time
+	now       func() time.Time
+}
+
+// NewLimiter returns a limiter for qps requests per second with bursts of burst requests; a
+// qps of zero or less disables rate limiting.
+func NewLimiter(qps float64, burst int) *Limiter {
+	l := &Limiter{limiter: rate.NewLimiter(rate.Inf, 0), now: time.Now}
+	l.SetLimit(qps, burst)
+	return l
+}
+
+// SetLimit changes the configured rate.
+func (l *Limiter) SetLimit(qps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.qps = qps
+	l.current = qps
+	l.changed = l.now()
+	l.throttled = time.Time{}
+	if qps <= 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+	l.limiter.SetLimit(rate.Limit(qps))
+	l.limiter.SetBurst(max(burst, 1))
+}
+
+// QPS returns the current rate, lower than the configured one while the API server throttles requests.
+func (l *Limiter) QPS() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current
+}
+
+// Wait blocks until a request can be sent.
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.limiter.Wait(ctx)
+}
+
+// Throttled halves the current rate, at most once in every throttleInterval, and tells whether it changed.
+func (l *Limiter) Throttled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.qps <= 0 || (!l.throttled.IsZero() && l.now().Sub(l.throttled) < throttleInterval) {
+		return false
+	}
+	current := max(l.current/2, l.qps*minimumShare)
+	if current == l.current {
+		return false
+	}
+	l.setCurrent(current)
+	l.throttled = l.changed
+	return true
+}
+
+// Succeeded raises the current rate back towards the configured one, once in every recoveryInterval.
+func (l *Limiter) Succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.qps <= 0 || l.current >= l.qps || l.now().Sub(l.changed) < recoveryInterval {
+		return
+	}
+	l.setCurrent(min(l.current+l.qps*recoveryShare, l.qps))
+}
+
+func (l *Limiter) setCurrent(current float64) {
+	l.current = current
+	l.changed = l.now()
+	l.limiter.SetLimit(rate.Limit(current))
+}
+
+// Transport waits for the limiter before each request, including retries, and adapts its rate
+// to the responses. OnThrottled is called when a 429 response lowers the rate.
+type Transport struct {
+	Next        http.RoundTripper
+	Limiter     *Limiter
+	OnThrottled func(request *http.Request, qps float64)
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	response, err := t.Next.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	if response.StatusCode == http.StatusTooManyRequests {
+		if t.Limiter.Throttled() && t.OnThrottled != nil {
+			t.OnThrottled(request, t.Limiter.QPS())
+		}
+	} else {
+		t.Limiter.Succeeded()
+	}
+	return response, nil
+}
@@ -0,0 +1,192 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is the time of a limiter, moved forward by the tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(qps float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 3, 25, 16, 42, 47, 0, time.UTC)}
+	l := NewLimiter(qps, burst)
+	l.now = func() time.Time { return clock.now }
+	l.SetLimit(qps, burst)
+	return l, clock
+}
+
+func TestLimiterThrottled(t *testing.T) {
+	l, clock := newTestLimiter(10, 10)
+
+	if !l.Throttled() || l.QPS() != 5 {
+		t.Fatalf("rate %v after a 429, expected 5", l.QPS())
+	}
+	// The other 429s of the wave do not lower the rate again
+	for range 20 {
+		if l.Throttled() {
+			t.Fatal("rate lowered twice in the same throttle interval")
+		}
+	}
+	if l.QPS() != 5 {
+		t.Errorf("rate %v after a wave of 429s, expected 5", l.QPS())
+	}
+
+	for _, want := range []float64{2.5, 1.25, 1, 1} {
+		clock.advance(throttleInterval)
+		l.Throttled()
+		if l.QPS() != want {
+			t.Errorf("rate %v, expected %v", l.QPS(), want)
+		}
+	}
+}
+
+func TestLimiterRecovery(t *testing.T) {
+	l, clock := newTestLimiter(10, 10)
+	l.Throttled()
+
+	l.Succeeded()
+	if l.QPS() != 5 {
+		t.Errorf("rate %v raised before the recovery interval", l.QPS())
+	}
+	for _, want := range []float64{6, 7, 8, 9, 10, 10} {
+		clock.advance(recoveryInterval)
+		l.Succeeded()
+		if l.QPS() != want {
+			t.Errorf("rate %v, expected %v", l.QPS(), want)
+		}
+	}
+
+	// A 429 right after a recovery step still lowers the rate
+	l.SetLimit(10, 10)
+	clock.advance(throttleInterval)
+	l.Throttled()
+	clock.advance(recoveryInterval)
+	l.Succeeded()
+	if !l.Throttled() || l.QPS() != 3 {
+		t.Errorf("rate %v after a 429 following a recovery, expected 3", l.QPS())
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := NewLimiter(0, 0)
+	if l.Throttled() || l.QPS() != 0 {
+		t.Errorf("disabled limiter throttled to %v", l.QPS())
+	}
+	start := time.Now()
+	for range 1000 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("disabled limiter waited %s", elapsed)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(50, 1)
+	start := time.Now()
+	for range 6 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request is sent at once, the next five at 50 per second
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("6 requests at 50 qps sent in %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewLimiter(0.1, 1).Wait(ctx); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var throttle atomic.Bool
+	throttle.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if throttle.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	l, clock := newTestLimiter(1000, 100)
+	var throttled []float64
+	var mu sync.Mutex
+	client := &http.Client{Transport: &Transport{
+		Next:    http.DefaultTransport,
+		Limiter: l,
+		OnThrottled: func(request *http.Request, qps float64) {
+			mu.Lock()
+			defer mu.Unlock()
+			throttled = append(throttled, qps)
+		},
+	}}
+	get := func() int {
+		response, err := client.Get(server.URL)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+
+	// A wave of concurrent 429s halves the rate once
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status := get(); status != http.StatusTooManyRequests {
+				t.Errorf("status %d", status)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(throttled) != 1 || throttled[0] != 500 || l.QPS() != 500 {
+		t.Errorf("throttled to %v, rate %v, expected 500 once", throttled, l.QPS())
+	}
+
+	throttle.Store(false)
+	clock.advance(recoveryInterval)
+	if status := get(); status != http.StatusOK {
+		t.Errorf("status %d", status)
+	}
+	if l.QPS() != 600 {
+		t.Errorf("rate %v after a success, expected 600", l.QPS())
+	}
+}
@@ -0,0 +1,295 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// PartSize is the size of the parts of multipart uploads to S3
+	PartSize = 64 * 1024 * 1024
+	// Retries is the number of attempts made for each request
+	Retries = 5
+
+	defaultEndpoint = "s3.amazonaws.com"
+)
+
+var (
+	// retryBackoff is the delay before the first retry of a presigned upload, doubled for the next ones
+	retryBackoff = time.Second
+	// maxPresignedSize is the largest file S3 accepts in a single PUT, and so through a presigned URL
+	maxPresignedSize int64 = 5 << 30
+)
+
+// Options describe where to upload the support package.
+type Options struct {
+	// Target is either s3://bucket/prefix or a presigned https:// URL
+	Target string
+	// Endpoint is the S3-compatible endpoint, by default taken from AWS_ENDPOINT_URL or AWS S3
+	Endpoint string
+}
+
+// Upload sends the files to the target and returns their location. Only one file can be
+// uploaded to a presigned URL. S3 credentials are read from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, or their MinIO equivalents.
+func Upload(ctx context.Context, options Options, files ...string) ([]string, error) {
+	target, err := url.Parse(options.Target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload target: %w", err)
+	}
+
+	switch target.Scheme {
+	case "s3":
+		return uploadS3(ctx, options, target, files)
+	case "https", "http":
+		if len(files) != 1 {
+			return nil, fmt.Errorf("a presigned URL can only receive a single file, not %d", len(files))
+		}
+		if err = uploadPresigned(ctx, options.Target, files[0]); err != nil {
+			return nil, err
+		}
+		return []string{target.Scheme + "://" + target.Host + target.Path}, nil
+	default:
+		return nil, fmt.Errorf("invalid upload target %q, must be s3://bucket/prefix or a presigned https:// URL", options.Target)
+	}
+}
+
+func uploadS3(ctx context.Context, options Options, target *url.URL, files []string) ([]string, error) {
+	bucket := target.Host
+	prefix := strings.TrimPrefix(target.Path, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid upload target %q, the bucket is missing", options.Target)
+	}
+
+	endpoint := options.Endpoint
+	for _, variable := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if endpoint == "" {
+			endpoint = os.Getenv(variable)
+		}
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	secure := !strings.HasPrefix(endpoint, "http://")
+	endpoint = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://"), "/")
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:      credentials.NewChainCredentials([]credentials.Provider{&credentials.EnvAWS{}, &credentials.EnvMinio{}}),
+		Secure:     secure,
+		Region:     region,
+		MaxRetries: Retries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create S3 client: %w", err)
+	}
+
+	var locations []string
+	for _, file := range files {
+		key := path.Join(prefix, filepath.Base(file))
+		info, err := client.FPutObject(ctx, bucket, key, file, minio.PutObjectOptions{
+			ContentType:    "application/octet-stream",
+			PartSize:       PartSize,
+			SendContentMd5: true,
+		})
+		if err != nil {
+			return locations, fmt.Errorf("unable to upload %s: %w", file, err)
+		}
+		if err = checkUploaded(ctx, client, bucket, key, file, info); err != nil {
+			return locations, err
+		}
+		locations = append(locations, "s3://"+bucket+"/"+key)
+	}
+	return locations, nil
+}
+
+// checkUploaded compares the size and, when available, the MD5 digest of the uploaded object with
+// the local file. Each part is already checked by the server through its Content-MD5 header.
+func checkUploaded(ctx context.Context, client *minio.Client, bucket string, key string, file string, info minio.UploadInfo) error {
+	stat, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to check uploaded object %s: %w", key, err)
+	}
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if stat.Size != fileInfo.Size() {
+		return fmt.Errorf("uploaded object %s has %d bytes instead of %d", key, stat.Size, fileInfo.Size())
+	}
+	if etag, ok := md5ETag(info.ETag); ok {
+		digest, err := fileMD5(file)
+		if err != nil {
+			return err
+		}
+		if etag != hex.EncodeToString(digest) {
+			return fmt.Errorf("checksum mismatch for uploaded %s: ETag %s", filepath.Base(file), etag)
+		}
+	}
+	return nil
+}
+
+// md5ETag returns the ETag of a single-part upload, which is the MD5 digest of the object.
+// The ETags of multipart uploads and of objects encrypted with KMS keys are not MD5 digests.
+func md5ETag(etag string) (string, bool) {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if _, err := hex.DecodeString(etag); err != nil || len(etag) != 2*md5.Size {
+		return "", false
+	}
+	return etag, true
+}
+
+func fileMD5(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := md5.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// uploadPresigned PUTs the file to a presigned URL, retrying with exponential backoff on
+// network errors and on 429 and 5xx responses. Files above maxPresignedSize are refused before
+// sending anything, as S3 would reject them once uploaded.
+func uploadPresigned(ctx context.Context, presignedURL string, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxPresignedSize {
+		return fmt.Errorf("%s is %s, above the %s a presigned URL accepts in a single PUT, upload it to an s3:// target instead, with --max-volume-size to split it if needed",
+			filepath.Base(file), humanize.IBytes(uint64(info.Size())), humanize.IBytes(uint64(maxPresignedSize)))
+	}
+
+	digest, err := fileMD5(file)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	backoff := retryBackoff
+	for attempt := 1; attempt <= Retries; attempt++ {
+		retry, err := putPresigned(ctx, presignedURL, file, digest)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(lastErr, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("unable to upload %s: %w", file, lastErr)
+}
+
+// presignedHeaders returns the headers a presigned URL was signed with, in lower case. Only
+// these are sent: the Content-Type and Content-MD5 headers are part of the string to sign of
+// AWS SigV2 and GCS V2 URLs, which are rejected when they are sent but were not signed. SigV4
+// URLs list their signed headers in a query parameter.
+func presignedHeaders(presignedURL string) map[string]bool {
+	headers := make(map[string]bool)
+	target, err := url.Parse(presignedURL)
+	if err != nil {
+		return headers
+	}
+	for name, values := range target.Query() {
+		if !strings.EqualFold(name, "X-Amz-SignedHeaders") && !strings.EqualFold(name, "X-Goog-SignedHeaders") {
+			continue
+		}
+		for _, value := range values {
+			for _, header := range strings.Split(value, ";") {
+				headers[strings.ToLower(strings.TrimSpace(header))] = true
+			}
+		}
+	}
+	return headers
+}
+
+// putPresigned makes a single upload attempt and tells whether a failure is worth retrying.
+func putPresigned(ctx context.Context, presignedURL string, file string, digest []byte) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, presignedURL, f)
+	if err != nil {
+		return false, err
+	}
+	request.ContentLength = info.Size()
+	signedHeaders := presignedHeaders(presignedURL)
+	if signedHeaders["content-type"] {
+		request.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if signedHeaders["content-md5"] {
+		request.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(digest))
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	if response.StatusCode/100 != 2 {
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		return retry, fmt.Errorf("upload failed with status %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	if etag, ok := md5ETag(response.Header.Get("ETag")); ok && etag != hex.EncodeToString(digest) {
+		return false, fmt.Errorf("checksum mismatch for uploaded %s: ETag %s", filepath.Base(file), etag)
+	}
+	return false, nil
+}
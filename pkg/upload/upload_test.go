@@ -0,0 +1,289 @@
+/**
+
+Copyright 2024 F5, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+**/
+
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// objectStore is a MinIO-style stand-in for an S3 bucket and for presigned URLs: it stores the
+// objects PUT to it, failing the first failures PUTs, and answers with their MD5 digest as ETag,
+// or with etag when set.
+type objectStore struct {
+	failures int
+	status   int
+	etag     string
+
+	mu      sync.Mutex
+	puts    int
+	headers []http.Header
+	objects map[string][]byte
+}
+
+func newObjectStore(t *testing.T, store *objectStore) *httptest.Server {
+	store.objects = make(map[string][]byte)
+	if store.status == 0 {
+		store.status = http.StatusServiceUnavailable
+	}
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.puts++
+		s.headers = append(s.headers, r.Header.Clone())
+		body, err := readBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.puts <= s.failures {
+			code := "SlowDown"
+			if s.status == http.StatusForbidden {
+				code = "AccessDenied"
+			}
+			w.WriteHeader(s.status)
+			fmt.Fprintf(w, "<Error><Code>%s</Code><Message>attempt %d</Message></Error>", code, s.puts)
+			return
+		}
+		s.objects[r.URL.Path] = body
+		w.Header().Set("ETag", s.objectETag(body))
+	case http.MethodHead:
+		body, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", s.objectETag(body))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (s *objectStore) objectETag(body []byte) string {
+	if s.etag != "" {
+		return `"` + s.etag + `"`
+	}
+	digest := md5.Sum(body)
+	return `"` + hex.EncodeToString(digest[:]) + `"`
+}
+
+// readBody reads the body of a PUT, decoding the aws-chunked encoding of streaming SigV4 uploads.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err = io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err = reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func writeBundle(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "nic-supportpkg-1711384966.tar.gz")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestUploadS3(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+	tests := []struct {
+		name     string
+		store    *objectStore
+		wantErr  string
+		wantPuts int
+	}{
+		{name: "uploaded", wantPuts: 1},
+		{name: "retried", store: &objectStore{failures: 2}, wantPuts: 3},
+		{name: "checksum mismatch", store: &objectStore{etag: "0123456789abcdef0123456789abcdef"}, wantErr: "checksum mismatch", wantPuts: 1},
+		{name: "denied", store: &objectStore{failures: Retries, status: http.StatusForbidden}, wantErr: "unable to upload", wantPuts: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := test.store
+			if store == nil {
+				store = &objectStore{}
+			}
+			server := newObjectStore(t, store)
+			file := writeBundle(t, "nic support package")
+
+			locations, err := Upload(context.Background(), Options{Target: "s3://tickets/12345", Endpoint: server.URL}, file)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, expected one with %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if store.puts != test.wantPuts {
+				t.Errorf("%d PUT requests, expected %d", store.puts, test.wantPuts)
+			}
+			if test.wantErr != "" {
+				return
+			}
+			if len(locations) != 1 || locations[0] != "s3://tickets/12345/nic-supportpkg-1711384966.tar.gz" {
+				t.Errorf("unexpected locations %v", locations)
+			}
+			if content := string(store.objects["/tickets/12345/nic-supportpkg-1711384966.tar.gz"]); content != "nic support package" {
+				t.Errorf("unexpected object %q", content)
+			}
+		})
+	}
+}
+
+func TestUploadPresigned(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+	digest := md5.Sum([]byte("nic support package"))
+	tests := []struct {
+		name    string
+		query   string
+		store   *objectStore
+		wantErr string
+		// wantPuts is the number of attempts, wantHeaders the headers sent with them
+		wantPuts    int
+		wantHeaders map[string]string
+		// maxSize lowers the size of the largest file uploaded
+		maxSize int64
+	}{
+		{
+			name:        "SigV2",
+			query:       "AWSAccessKeyId=minio&Expires=1711388566&Signature=c2lnbmF0dXJl",
+			wantPuts:    1,
+			wantHeaders: map[string]string{"Content-Type": "", "Content-Md5": ""},
+		},
+		{
+			name:        "SigV4 without content headers",
+			query:       "X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-SignedHeaders=host&X-Amz-Signature=00",
+			wantPuts:    1,
+			wantHeaders: map[string]string{"Content-Type": "", "Content-Md5": ""},
+		},
+		{
+			name:        "SigV4 with content headers",
+			query:       "X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-SignedHeaders=content-md5%3Bcontent-type%3Bhost&X-Amz-Signature=00",
+			wantPuts:    1,
+			wantHeaders: map[string]string{"Content-Type": "application/octet-stream", "Content-Md5": base64.StdEncoding.EncodeToString(digest[:])},
+		},
+		{name: "retried", store: &objectStore{failures: 2}, wantPuts: 3},
+		{name: "throttled", store: &objectStore{failures: 1, status: http.StatusTooManyRequests}, wantPuts: 2},
+		{name: "retries exhausted", store: &objectStore{failures: Retries}, wantErr: "503", wantPuts: Retries},
+		{name: "expired", store: &objectStore{failures: 1, status: http.StatusForbidden}, wantErr: "403", wantPuts: 1},
+		{name: "checksum mismatch", store: &objectStore{etag: "0123456789abcdef0123456789abcdef"}, wantErr: "checksum mismatch", wantPuts: 1},
+		{name: "too large", maxSize: 16, wantErr: "upload it to an s3:// target", wantPuts: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := test.store
+			if store == nil {
+				store = &objectStore{}
+			}
+			if test.maxSize > 0 {
+				maxPresignedSize = test.maxSize
+				t.Cleanup(func() { maxPresignedSize = 5 << 30 })
+			}
+			if test.maxSize > 0 {
+				maxPresignedSize = test.maxSize
+				t.Cleanup(func() { maxPresignedSize = 5 << 30 })
+			}
+			server := newObjectStore(t, store)
+			file := writeBundle(t, "nic support package")
+			presignedURL := server.URL + "/tickets/12345/nic.tar.gz?" + test.query
+
+			locations, err := Upload(context.Background(), Options{Target: presignedURL}, file)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, expected one with %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if store.puts != test.wantPuts {
+				t.Errorf("%d PUT requests, expected %d", store.puts, test.wantPuts)
+			}
+			for name, want := range test.wantHeaders {
+				if got := store.headers[0].Get(name); got != want {
+					t.Errorf("header %s is %q, expected %q", name, got, want)
+				}
+			}
+			if test.wantErr != "" {
+				return
+			}
+			if len(locations) != 1 || locations[0] != server.URL+"/tickets/12345/nic.tar.gz" {
+				t.Errorf("unexpected locations %v", locations)
+			}
+			if content := string(store.objects["/tickets/12345/nic.tar.gz"]); content != "nic support package" {
+				t.Errorf("unexpected object %q", content)
+			}
+		})
+	}
+}
+
+func TestUploadPresignedSeveralFiles(t *testing.T) {
+	if _, err := Upload(context.Background(), Options{Target: "https://example.com/upload"}, "a", "b"); err == nil {
+		t.Error("expected an error for several files")
+	}
+}